@@ -27,7 +27,8 @@ local gosources = [
                 from: images.golang,
                 workdir: "/app",
                 copy: copy('.git', '.git'),
-                command: "git -c log.showsignature=false show -s --format=%H:%ct"
+                command: "git -c log.showsignature=false show -s --format=%H:%ct",
+                useCache: true,
             }
         },
 
@@ -35,12 +36,14 @@ local gosources = [
             all: ["build", "check", "modulesvendor"],
 
             gosources: {
+                hidden: true,
                 from: "scratch",
                 workdir: "/app",
                 copy: [copy(source, source) for source in gosources]
             },
 
             gobase: {
+                hidden: true,
                 from: images.golang,
                 workdir: "/app",
                 env: {
@@ -54,6 +57,7 @@ local gosources = [
             },
 
             build: {
+                description: "Build brewkit binary into ./bin",
                 from: "gobase",
                 cache: gocache,
                 workdir: "/app",
@@ -71,6 +75,7 @@ local gosources = [
             },
 
             modules: {
+                description: "Tidy go modules",
                 from: "gobase",
                 cache: gocache,
                 workdir: "/app",
@@ -83,6 +88,7 @@ local gosources = [
 
             // export local copy of dependencies for ide index
             modulesvendor: {
+                description: "Export go modules into ./vendor",
                 from: "gobase",
                 workdir: "/app",
                 cache: gocache,
@@ -97,6 +103,7 @@ local gosources = [
             check: ["test", "lint"],
 
             test: {
+                description: "Run unit tests",
                 from: "gobase",
                 workdir: "/app",
                 cache: gocache,
@@ -104,6 +111,7 @@ local gosources = [
             },
 
             lint: {
+                description: "Run golangci-lint",
                 from: images.golangcilint,
                 workdir: "/app",
                 cache: gocache,
@@ -1,19 +1,35 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"path"
+	"sort"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 
+	"github.com/ispringtech/brewkit/internal/backend/api"
 	backendapp "github.com/ispringtech/brewkit/internal/backend/app/build"
-	"github.com/ispringtech/brewkit/internal/backend/infrastructure/docker"
+	appdocker "github.com/ispringtech/brewkit/internal/backend/app/docker"
+	appreporter "github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/reporter"
 	"github.com/ispringtech/brewkit/internal/backend/infrastructure/ssh"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
 	"github.com/ispringtech/brewkit/internal/frontend/app/builddefinition"
+	"github.com/ispringtech/brewkit/internal/frontend/app/graph"
 	"github.com/ispringtech/brewkit/internal/frontend/app/service"
 	infrabuilddefinition "github.com/ispringtech/brewkit/internal/frontend/infrastructure/builddefinition"
 )
 
+const (
+	defaultReportFile = "brewkit-report.json"
+)
+
 func build(workdir string) *cli.Command {
 	return &cli.Command{
 		Name:  "build",
@@ -32,6 +48,37 @@ func build(workdir string) *cli.Command {
 				Aliases: []string{"p"},
 				EnvVars: []string{"BREWKIT_FORCE_PULL"},
 			},
+			&cli.IntFlag{
+				Name:    "jobs",
+				Usage:   "Max count of independent targets executed concurrently",
+				Aliases: []string{"j"},
+				Value:   1,
+				EnvVars: []string{"BREWKIT_JOBS"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "platform",
+				Usage:   "Build targets for platforms, e.g. linux/amd64,linux/arm64. Outputs are exported per platform when several platforms passed",
+				EnvVars: []string{"BREWKIT_PLATFORM"},
+			},
+			&cli.StringFlag{
+				Name:    "report",
+				Usage:   "Write report with status and duration of built targets and vars in format: json",
+				EnvVars: []string{"BREWKIT_REPORT"},
+			},
+			&cli.StringFlag{
+				Name:    "report-file",
+				Usage:   "Path to write report to",
+				Value:   defaultReportFile,
+				EnvVars: []string{"BREWKIT_REPORT_FILE"},
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print execution plan with generated Dockerfiles without executing targets",
+			},
+			&cli.BoolFlag{
+				Name:  "stub-vars",
+				Usage: "Use placeholders instead of calculating vars in dry run",
+			},
 		},
 		Action: executeBuild,
 		Subcommands: []*cli.Command{
@@ -40,6 +87,19 @@ func build(workdir string) *cli.Command {
 				Usage:  "Print full parsed and verified build definition",
 				Action: executeBuildDefinition,
 			},
+			{
+				Name:      "graph",
+				Usage:     "Print graph of targets with from, copy and dependsOn edges",
+				ArgsUsage: "[targets...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Graph format: dot or mermaid",
+						Value: string(graph.DOT),
+					},
+				},
+				Action: executeBuildGraph,
+			},
 			{
 				Name:   "definition-debug",
 				Usage:  "Print compiled build definition in raw JSON, useful for debugging complex build definitions",
@@ -53,40 +113,140 @@ type buildOps struct {
 	commonOpt
 	BuildDefinition string
 	ForcePull       bool
+	Jobs            int
+	Platforms       []string
+	Report          string
+	ReportFile      string
+	DryRun          bool
+	StubVars        bool
 }
 
 func (o *buildOps) scan(ctx *cli.Context) {
 	o.commonOpt.scan(ctx)
 	o.BuildDefinition = ctx.String("definition")
 	o.ForcePull = ctx.Bool("force-pull")
+	o.Jobs = ctx.Int("jobs")
+	o.Platforms = ctx.StringSlice("platform")
+	o.Report = ctx.String("report")
+	o.ReportFile = ctx.String("report-file")
+	o.DryRun = ctx.Bool("dry-run")
+	o.StubVars = ctx.Bool("stub-vars")
 }
 
 func executeBuild(ctx *cli.Context) error {
 	var opts buildOps
 	opts.scan(ctx)
 
-	buildService, err := makeBuildService(opts)
+	buildService, shutdown, err := makeBuildService(ctx.Context, opts)
 	if err != nil {
 		return err
 	}
+	defer shutdown()
+
+	if opts.DryRun {
+		plan, err2 := buildService.Plan(ctx.Context, service.PlanParams{
+			Targets:         ctx.Args().Slice(),
+			BuildDefinition: opts.BuildDefinition,
+			StubVars:        opts.StubVars,
+			Jobs:            opts.Jobs,
+			Platforms:       opts.Platforms,
+		})
+		if err2 != nil {
+			return err2
+		}
+
+		printPlan(makeLogger(opts.verbose), plan)
+		return nil
+	}
+
+	if opts.StubVars {
+		return errors.New("--stub-vars can be used only with --dry-run")
+	}
 
 	return buildService.Build(ctx.Context, service.BuildParams{
 		Targets:         ctx.Args().Slice(),
 		BuildDefinition: opts.BuildDefinition,
 		ForcePull:       opts.ForcePull,
+		Jobs:            opts.Jobs,
+		Platforms:       opts.Platforms,
 	})
 }
 
+func printPlan(log logger.Logger, plan api.Plan) {
+	if len(plan.Vars) > 0 {
+		log.Outputf("Vars:\n")
+		for _, v := range plan.Vars {
+			var stub string
+			if v.Stub {
+				stub = " (stub)"
+			}
+			log.Outputf("    %s=%s%s\n", v.Name, v.Value, stub)
+		}
+		log.Outputf("\n")
+	}
+
+	for i, step := range plan.Steps {
+		flags := []string{"--target", step.Target}
+		if len(step.Platforms) > 0 {
+			flags = append(flags, "--platform", strings.Join(step.Platforms, ","))
+		}
+		if maybe.Valid(step.Output) {
+			flags = append(flags, "--output", maybe.Just(step.Output))
+		}
+		if maybe.Valid(step.Image) {
+			image := maybe.Just(step.Image)
+			for _, tag := range image.Tags {
+				flags = append(flags, "--tag", tag)
+			}
+			labels := make([]string, 0, len(image.Labels))
+			for k, v := range image.Labels {
+				labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+			}
+			sort.Strings(labels)
+			for _, label := range labels {
+				flags = append(flags, "--label", label)
+			}
+			if image.Push {
+				flags = append(flags, "--push")
+			}
+			if image.Load {
+				flags = append(flags, "--load")
+			}
+		}
+		for _, entitlement := range step.Allow {
+			flags = append(flags, "--allow", entitlement)
+		}
+		for _, secret := range step.Secrets {
+			flags = append(flags, "--secret", fmt.Sprintf("id=%s", secret))
+		}
+		for _, agent := range step.SSHAgents {
+			flags = append(flags, "--ssh", fmt.Sprintf("%s=%s", agent.ID, agent.SourcePath))
+		}
+
+		log.Outputf("Step %d/%d: %s\n", i+1, len(plan.Steps), step.Vertex)
+		log.Outputf("    build %s\n", strings.Join(flags, " "))
+		for _, o := range step.Batch {
+			log.Outputf("    export %s to %s\n", o.Stage, o.Output)
+		}
+		log.Outputf("    Dockerfile:\n")
+		for _, line := range strings.Split(strings.TrimSuffix(step.Dockerfile, "\n"), "\n") {
+			log.Outputf("        %s\n", line)
+		}
+		log.Outputf("\n")
+	}
+}
+
 func executeBuildDefinition(ctx *cli.Context) error {
 	var opts buildOps
 	opts.scan(ctx)
 
 	logger := makeLogger(opts.verbose)
 
-	buildService, err := makeBuildService(opts)
+	buildService, shutdown, err := makeBuildService(ctx.Context, opts)
 	if err != nil {
 		return err
 	}
+	defer shutdown()
 
 	buildDefinition, err := buildService.DumpBuildDefinition(ctx.Context, opts.BuildDefinition)
 	if err != nil {
@@ -98,16 +258,47 @@ func executeBuildDefinition(ctx *cli.Context) error {
 	return nil
 }
 
+func executeBuildGraph(ctx *cli.Context) error {
+	var opts buildOps
+	opts.scan(ctx)
+
+	logger := makeLogger(opts.verbose)
+
+	buildService, shutdown, err := makeBuildService(ctx.Context, opts)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	g, err := buildService.Graph(ctx.Context, service.GraphParams{
+		Targets:         ctx.Args().Slice(),
+		BuildDefinition: opts.BuildDefinition,
+	})
+	if err != nil {
+		return err
+	}
+
+	output, err := g.Render(graph.Format(ctx.String("format")))
+	if err != nil {
+		return err
+	}
+
+	logger.Outputf(output)
+
+	return nil
+}
+
 func executeCompileBuildDefinition(ctx *cli.Context) error {
 	var opts buildOps
 	opts.scan(ctx)
 
 	logger := makeLogger(opts.verbose)
 
-	buildService, err := makeBuildService(opts)
+	buildService, shutdown, err := makeBuildService(ctx.Context, opts)
 	if err != nil {
 		return err
 	}
+	defer shutdown()
 
 	buildDefinition, err := buildService.DumpCompiledBuildDefinition(ctx.Context, opts.BuildDefinition)
 	if err != nil {
@@ -119,31 +310,59 @@ func executeCompileBuildDefinition(ctx *cli.Context) error {
 	return nil
 }
 
-func makeBuildService(options buildOps) (service.BuildService, error) {
+// makeBuildService returns service and func which should be called after use to flush traces
+func makeBuildService(ctx context.Context, options buildOps) (service.BuildService, func(), error) {
 	parser := infrabuilddefinition.Parser{}
 
 	logger := makeLogger(options.verbose)
 
+	reportPath := maybe.NewNone[string]()
+	switch options.Report {
+	case "":
+	case jsonFormat:
+		reportPath = maybe.NewJust(options.ReportFile)
+	default:
+		return nil, nil, errors.Errorf("unknown report format %s", options.Report)
+	}
+
 	config, err := parseConfig(options.configPath, logger)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	dockerClient, err := docker.NewClient(options.dockerClientConfigPath, logger)
-	if err != nil {
-		return nil, err
+	var buildReporter appreporter.Reporter
+	switch options.logFormat {
+	case textLogFormat:
+		buildReporter = reporter.NewReporter(logger, reportPath)
+	case jsonLogFormat:
+		buildReporter = reporter.NewEventReporter(os.Stdout, options.verbose, reportPath)
+	default:
+		return nil, nil, errors.Errorf("unknown log format %s", options.logFormat)
 	}
 
-	agentProvider, err := ssh.NewAgentProvider()
+	tracerProvider, err := makeTracerProvider(ctx, config, logger)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	shutdown := func() {
+		shutdownTracerProvider(tracerProvider, logger)
 	}
 
+	// Client is created on first use, so dry run with stub vars, graph and definition do not require docker or buildkitd
+	dockerClient := newLazyDockerClient(func() (appdocker.Client, error) {
+		// Output of docker client is logged via reporter, so it is emitted as events with json log format
+		return makeDockerClient(options.commonOpt, config, tracerProvider, reporterLogger{
+			Logger:   logger,
+			reporter: buildReporter,
+		})
+	})
+
 	backendBuildService := backendapp.NewBuildService(
 		dockerClient,
 		DockerfileImage,
-		agentProvider,
-		logger,
+		ssh.NewAgentProvider(),
+		buildReporter,
+		tracerProvider.Tracer(appID),
 	)
 
 	return service.NewBuildService(
@@ -151,5 +370,19 @@ func makeBuildService(options buildOps) (service.BuildService, error) {
 		builddefinition.NewBuilder(),
 		backendBuildService,
 		config,
-	), nil
+	), shutdown, nil
+}
+
+// reporterLogger writes logs through build reporter and outputs to user via Logger
+type reporterLogger struct {
+	logger.Logger
+	reporter appreporter.Reporter
+}
+
+func (l reporterLogger) Logf(format string, a ...any) {
+	l.reporter.Logf(format, a...)
+}
+
+func (l reporterLogger) Debugf(format string, a ...any) {
+	l.reporter.Debugf(format, a...)
 }
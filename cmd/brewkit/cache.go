@@ -2,9 +2,9 @@ package main
 
 import (
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/trace"
 
 	backendcache "github.com/ispringtech/brewkit/internal/backend/app/cache"
-	"github.com/ispringtech/brewkit/internal/backend/infrastructure/docker"
 	"github.com/ispringtech/brewkit/internal/frontend/app/service"
 )
 
@@ -36,7 +36,12 @@ func cacheClear() *cli.Command {
 
 			logger := makeLogger(opts.verbose)
 
-			dockerClient, err := docker.NewClient(opts.dockerClientConfigPath, logger)
+			config, err := parseConfig(opts.configPath, logger)
+			if err != nil {
+				return err
+			}
+
+			dockerClient, err := makeDockerClient(opts, config, trace.NewNoopTracerProvider(), logger)
 			if err != nil {
 				return err
 			}
@@ -1,26 +1,44 @@
 package main
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/trace"
 
+	appdocker "github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/buildkit"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/docker"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/tracing"
 	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	appconfig "github.com/ispringtech/brewkit/internal/frontend/app/config"
 	infraconfig "github.com/ispringtech/brewkit/internal/frontend/infrastructure/config"
 )
 
+const (
+	buildkitHostEnv = "BUILDKIT_HOST"
+
+	tracerShutdownTimeout = 5 * time.Second
+
+	textLogFormat = "text"
+	jsonLogFormat = "json" // Newline-delimited JSON events
+)
+
 type commonOpt struct {
 	configPath             string
 	verbose                bool
+	logFormat              string
 	dockerClientConfigPath maybe.Maybe[string]
 }
 
 func (o *commonOpt) scan(ctx *cli.Context) {
 	o.configPath = ctx.String("config")
 	o.verbose = ctx.Bool("verbose")
+	o.logFormat = ctx.String("log-format")
 	dockerConfigPath := ctx.String("docker-config")
 	if dockerConfigPath != "" {
 		o.dockerClientConfigPath = maybe.NewJust(dockerConfigPath)
@@ -43,3 +61,55 @@ func parseConfig(configPath string, log logger.Logger) (appconfig.Config, error)
 	}
 	return c, err
 }
+
+func makeDockerClient(
+	opts commonOpt,
+	config appconfig.Config,
+	tracerProvider trace.TracerProvider,
+	log logger.Logger,
+) (appdocker.Client, error) {
+	switch config.Backend.Type {
+	case appconfig.BuildKitBackend:
+		address := maybe.MapNone(config.Backend.Address, func() string {
+			if host, ok := os.LookupEnv(buildkitHostEnv); ok {
+				return host
+			}
+			return appconfig.DefaultBuildKitAddress
+		})
+		log.Debugf("use buildkit backend on %s\n", address)
+
+		return buildkit.NewClient(address, tracerProvider, log), nil
+	case appconfig.DockerBackend:
+		return docker.NewClient(opts.dockerClientConfigPath, log)
+	default:
+		return nil, errors.Errorf("unknown backend type %s", config.Backend.Type)
+	}
+}
+
+// makeTracerProvider returns provider that exports traces when tracing is configured in config or by OTEL_EXPORTER_OTLP_* env
+func makeTracerProvider(ctx context.Context, config appconfig.Config, log logger.Logger) (tracing.Provider, error) {
+	params := tracing.Params{
+		Enabled: maybe.Valid(config.Tracing) || tracing.EnvConfigured(),
+		Version: Commit,
+	}
+	if maybe.Valid(config.Tracing) {
+		params.Endpoint = maybe.Just(config.Tracing).Endpoint
+		params.Insecure = maybe.Just(config.Tracing).Insecure
+	}
+	if params.Enabled {
+		log.Debugf("export traces to OTLP collector %s\n", params.Endpoint)
+	}
+
+	return tracing.NewProvider(ctx, params)
+}
+
+// shutdownTracerProvider flushes recorded spans, it is not bound to build context since spans of canceled build are exported too
+func shutdownTracerProvider(provider tracing.Provider, log logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+
+	err := provider.Shutdown(ctx)
+	if err != nil {
+		log.Logf("failed to export traces: %s\n", err)
+	}
+}
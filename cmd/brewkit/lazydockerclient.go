@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	appdocker "github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+func newLazyDockerClient(create func() (appdocker.Client, error)) appdocker.Client {
+	return &lazyDockerClient{
+		create: create,
+	}
+}
+
+// lazyDockerClient creates client on first request, so commands that do not build targets work without docker CLI
+type lazyDockerClient struct {
+	create func() (appdocker.Client, error)
+
+	once   sync.Once
+	client appdocker.Client
+	err    error
+}
+
+func (c *lazyDockerClient) Build(ctx context.Context, d dockerfile.Dockerfile, params appdocker.BuildParams) (appdocker.BuildResult, error) {
+	client, err := c.get()
+	if err != nil {
+		return appdocker.BuildResult{}, err
+	}
+	return client.Build(ctx, d, params)
+}
+
+func (c *lazyDockerClient) Value(ctx context.Context, d dockerfile.Dockerfile, params appdocker.ValueParams) ([]byte, error) {
+	client, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return client.Value(ctx, d, params)
+}
+
+func (c *lazyDockerClient) PullImage(ctx context.Context, img string) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.PullImage(ctx, img)
+}
+
+func (c *lazyDockerClient) ListImages(ctx context.Context, images []string) ([]appdocker.Image, error) {
+	client, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return client.ListImages(ctx, images)
+}
+
+func (c *lazyDockerClient) BuildImage(
+	ctx context.Context,
+	d dockerfile.Dockerfile,
+	params appdocker.BuildImageParams,
+) (appdocker.BuildResult, error) {
+	client, err := c.get()
+	if err != nil {
+		return appdocker.BuildResult{}, err
+	}
+	return client.BuildImage(ctx, d, params)
+}
+
+func (c *lazyDockerClient) ClearCache(ctx context.Context, params appdocker.ClearCacheParams) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.ClearCache(ctx, params)
+}
+
+func (c *lazyDockerClient) get() (appdocker.Client, error) {
+	c.once.Do(func() {
+		c.client, c.err = c.create()
+	})
+	return c.client, c.err
+}
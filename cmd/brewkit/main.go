@@ -7,14 +7,19 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
 	"github.com/ispringtech/brewkit/internal/dockerfile"
 	appconfig "github.com/ispringtech/brewkit/internal/frontend/app/config"
 )
 
 const (
 	appID = "brewkit"
+
+	// targetFailedExitCode is returned when build of target or var fails, so it can be distinguished from brewkit errors
+	targetFailedExitCode = 2
 )
 
 // These variables come from -ldflags settings
@@ -31,7 +36,13 @@ func main() {
 
 	err := runApp(ctx, os.Args)
 	if err != nil {
-		stdlog.Fatal(err)
+		stdlog.Print(err)
+
+		var requestErr *docker.RequestError
+		if errors.As(err, &requestErr) {
+			os.Exit(targetFailedExitCode)
+		}
+		os.Exit(1)
 	}
 }
 
@@ -54,6 +65,7 @@ func runApp(ctx context.Context, args []string) error {
 		Usage: "Container-native build system",
 		Commands: []*cli.Command{
 			build(workdir),
+			targets(workdir),
 			config(),
 			version(),
 			cache(),
@@ -72,6 +84,12 @@ func runApp(ctx context.Context, args []string) error {
 				Usage:   "Verbose output to stderr",
 				Aliases: []string{"v"},
 			},
+			&cli.StringFlag{
+				Name:    "log-format",
+				Usage:   "Format of build log: text or json. With json build progress is written to stdout as newline-delimited JSON events",
+				EnvVars: []string{"BREWKIT_LOG_FORMAT"},
+				Value:   textLogFormat,
+			},
 			&cli.StringFlag{
 				Name:    "docker-config",
 				Usage:   "Path to docker client config",
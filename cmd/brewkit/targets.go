@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
+	"github.com/ispringtech/brewkit/internal/frontend/app/service"
+	infrabuilddefinition "github.com/ispringtech/brewkit/internal/frontend/infrastructure/builddefinition"
+)
+
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+)
+
+func targets(workdir string) *cli.Command {
+	return &cli.Command{
+		Name:  "targets",
+		Usage: "List targets of build definition",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "definition",
+				Usage:   "Config with build definition",
+				Aliases: []string{"d"},
+				Value:   path.Join(workdir, buildconfig.DefaultName),
+				EnvVars: []string{"BREWKIT_BUILD_CONFIG"},
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format: table or json",
+				Value: tableFormat,
+			},
+			&cli.BoolFlag{
+				Name:    "all",
+				Usage:   "Show hidden targets too",
+				Aliases: []string{"a"},
+			},
+		},
+		Action: executeTargets,
+	}
+}
+
+func executeTargets(ctx *cli.Context) error {
+	var opts commonOpt
+	opts.scan(ctx)
+
+	logger := makeLogger(opts.verbose)
+
+	targetsService := service.NewTargetsService(infrabuilddefinition.Parser{})
+
+	targetsInfo, err := targetsService.ListTargets(ctx.Context, service.ListTargetsParams{
+		BuildDefinition: ctx.String("definition"),
+		ShowHidden:      ctx.Bool("all"),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch format := ctx.String("format"); format {
+	case tableFormat:
+		b := &strings.Builder{}
+		w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+		_, _ = w.Write([]byte("NAME\tDESCRIPTION\tFROM\tDEPENDS ON\tOUTPUT\n"))
+		for _, t := range targetsInfo {
+			output := "no"
+			if t.Output {
+				output = "yes"
+			}
+			_, _ = w.Write([]byte(strings.Join([]string{
+				t.Name,
+				t.Description,
+				t.From,
+				strings.Join(t.DependsOn, ","),
+				output,
+			}, "\t") + "\n"))
+		}
+		err = w.Flush()
+		if err != nil {
+			return errors.Wrap(err, "failed to render targets table")
+		}
+
+		logger.Outputf("%s", b.String())
+	case jsonFormat:
+		data, err2 := json.MarshalIndent(targetsInfo, "", "    ")
+		if err2 != nil {
+			return errors.WithStack(err2)
+		}
+
+		logger.Outputf("%s\n", data)
+	default:
+		return errors.Errorf("unknown format %s, supported formats: %s, %s", format, tableFormat, jsonFormat)
+	}
+
+	return nil
+}
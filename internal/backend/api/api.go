@@ -5,7 +5,19 @@ import (
 )
 
 type BuildParams struct {
-	ForcePull bool
+	ForcePull   bool
+	Jobs        int // Max count of targets executed concurrently
+	RemoteCache []RemoteCache
+	Platforms   []string      // Build targets for each platform, outputs are exported per platform when several platforms passed
+	SSHAgents   []SSHAgentSrc // Named ssh agents from host config
+}
+
+type PlanParams struct {
+	StubVars    bool // Use placeholders instead of calculating vars
+	Jobs        int  // Max count of targets executed concurrently
+	Platforms   []string
+	SSHAgents   []SSHAgentSrc
+	RemoteCache []RemoteCache
 }
 
 type ClearParams struct {
@@ -14,6 +26,8 @@ type ClearParams struct {
 
 type BuilderAPI interface {
 	Build(ctx context.Context, v Vertex, vars []Var, secretsSrc []SecretSrc, params BuildParams) error
+	// Plan returns steps that Build would execute without executing targets
+	Plan(ctx context.Context, v Vertex, vars []Var, secretsSrc []SecretSrc, params PlanParams) (Plan, error)
 }
 
 type CacheAPI interface {
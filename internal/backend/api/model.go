@@ -20,31 +20,40 @@ type Stage struct {
 	WorkDir  string               // Working directory for stage
 	Env      map[string]string    // Stage env
 	Cache    []Cache              // Pluggable cache for build systems
+	Tmpfs    []Tmpfs              // In-memory scratch directories for command
 	Copy     []Copy               // Copy local or build stages artifacts
+	Mounts   []Mount              // Read-only bind mounts into command
 	Network  maybe.Maybe[Network] // Network options
 	SSH      maybe.Maybe[SSH]     // SSH access options
 	Secrets  []Secret
 	Command  maybe.Maybe[string] // Command for stage
-	Output   maybe.Maybe[Output] // Output artifacts from builder
+	Outputs  []Output            // Output artifacts from builder
+	Image    maybe.Maybe[Image]  // Image built from stage
 }
 
+// Image built from stage
 type Image struct {
-	From       string
-	Dockerfile string
+	Tags   []string
+	Labels map[string]string
+	Push   bool // Push image to registry
+	Load   bool // Load image into local docker daemon
 }
 
 type Var struct {
-	Name     string // Unique Var name
-	From     string
-	Platform maybe.Maybe[string]
-	WorkDir  string
-	Env      map[string]string
-	Cache    []Cache
-	Copy     []CopyVar
-	Secrets  []Secret
-	Network  maybe.Maybe[Network]
-	SSH      maybe.Maybe[SSH]
-	Command  string
+	Name      string // Unique Var name
+	From      string
+	Platform  maybe.Maybe[string]
+	WorkDir   string
+	Env       map[string]string
+	Cache     []Cache
+	Tmpfs     []Tmpfs
+	Copy      []Copy // Copy local, image or build stages artifacts
+	Secrets   []Secret
+	Network   maybe.Maybe[Network]
+	SSH       maybe.Maybe[SSH]
+	Command   string
+	UseCache  bool     // Recalculate var only when its inputs changed
+	DependsOn []string // Vars which values used by Var
 }
 
 type Copy struct {
@@ -53,16 +62,67 @@ type Copy struct {
 	Dst  string
 }
 
-// CopyVar is Copy instruction for var
-type CopyVar struct {
-	From maybe.Maybe[string]
+// Tmpfs mounts RAM-backed directory into Path for command, its content does not get into layer
+type Tmpfs struct {
+	Path string
+	Size maybe.Maybe[string] // Size limit in bytes with optional unit suffix
+}
+
+// Mount binds Src of build context or From target or image into Dst for command
+type Mount struct {
+	From maybe.Maybe[either.Either[*Vertex, string]]
 	Src  string
 	Dst  string
 }
 
 type Cache struct {
-	ID   string
-	Path string
+	ID       string
+	Path     string
+	Sharing  maybe.Maybe[CacheSharing]
+	UID      maybe.Maybe[int]
+	GID      maybe.Maybe[int]
+	Mode     maybe.Maybe[string] // File mode of new cache directory in octal notation
+	ReadOnly bool
+	From     maybe.Maybe[either.Either[*Vertex, string]] // Target or image to seed cache with
+	Source   maybe.Maybe[string]                         // Path in From to seed cache with
+}
+
+type CacheSharing string
+
+const (
+	SharedCache  CacheSharing = "shared"  // Cache used by concurrent builds simultaneously
+	PrivateCache CacheSharing = "private" // New cache created for concurrent build
+	LockedCache  CacheSharing = "locked"  // Concurrent build waits until cache released
+)
+
+// Sources returns targets and images from which stage takes files by copy, cache and mount
+func (s Stage) Sources() []either.Either[*Vertex, string] {
+	return sources(s.Copy, s.Cache, s.Mounts)
+}
+
+// Sources returns targets and images from which var takes files by copy and cache
+func (v Var) Sources() []either.Either[*Vertex, string] {
+	return sources(v.Copy, v.Cache, nil)
+}
+
+func sources(copyDirs []Copy, caches []Cache, mounts []Mount) []either.Either[*Vertex, string] {
+	var res []either.Either[*Vertex, string]
+	for _, c := range copyDirs {
+		if maybe.Valid(c.From) {
+			res = append(res, maybe.Just(c.From))
+		}
+	}
+	for _, c := range caches {
+		if maybe.Valid(c.From) {
+			res = append(res, maybe.Just(c.From))
+		}
+	}
+	for _, m := range mounts {
+		if maybe.Valid(m.From) {
+			res = append(res, maybe.Just(m.From))
+		}
+	}
+	return res
 }
 
 type Secret struct {
@@ -75,13 +135,87 @@ type SecretSrc struct {
 	SourcePath string
 }
 
-type SSH struct{}
+// DefaultSSHAgent is ID of ssh agent forwarded from SSH_AUTH_SOCK unless host config declares agent with same ID
+const DefaultSSHAgent = "default"
+
+type SSH struct {
+	ID string // ID of ssh agent from host config
+}
+
+// SSHAgentSrc is ssh agent socket or private key file on host
+type SSHAgentSrc struct {
+	ID         string
+	SourcePath string
+}
+
+type RemoteCacheType string
+
+const (
+	RegistryCache RemoteCacheType = "registry"
+	LocalCache    RemoteCacheType = "local"
+	InlineCache   RemoteCacheType = "inline"
+)
+
+// RemoteCache describes backend to import build cache from and export to
+type RemoteCache struct {
+	Type     RemoteCacheType
+	Ref      string // Image reference for registry and inline cache
+	Path     string // Directory for local cache
+	Mode     string // Cache export mode: min or max
+	ReadOnly bool   // Only import cache
+}
+
+const (
+	DefaultNetwork = "default"
+	NoneNetwork    = "none" // Isolated network without external access
+	HostNetwork    = "host" // Host network, requires network.host entitlement of builder
+)
 
 type Network struct {
-	Network string // It may be Host and other docker networks
+	Network string // One of DefaultNetwork, NoneNetwork, HostNetwork
 }
 
+type OutputType string
+
+const (
+	LocalOutput OutputType = "local" // Export artifact into directory
+	TarOutput   OutputType = "tar"   // Export artifact into tarball
+	OCIOutput   OutputType = "oci"   // Export artifact as OCI image layout tarball
+)
+
 type Output struct {
 	Artifact string
-	Local    string
+	Local    string              // Destination on host
+	Rename   maybe.Maybe[string] // Name of artifact in destination
+	Type     OutputType
+}
+
+type Plan struct {
+	Vars  []PlanVar
+	Steps []PlanStep // Steps in execution order
+}
+
+type PlanVar struct {
+	Name  string
+	Value string
+	Stub  bool // Value is placeholder, var is not calculated
+}
+
+type PlanStep struct {
+	Vertex     string              // Name of vertex executed by step
+	Target     string              // Stage passed to docker as target
+	Platforms  []string            // Target platforms
+	Allow      []string            // Entitlements granted to build
+	Output     maybe.Maybe[string] // Local path to save artifacts
+	Image      maybe.Maybe[Image]  // Image built by step
+	Secrets    []string            // IDs of passed secrets
+	SSHAgents  []SSHAgentSrc       // Forwarded ssh agents
+	Batch      []BatchOutput       // Outputs of targets solved together by step, which are moved from Output
+	Dockerfile string
+}
+
+// BatchOutput is output of target exported by batch step
+type BatchOutput struct {
+	Stage  string // Directory of output in export of batch
+	Output string
 }
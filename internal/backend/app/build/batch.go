@@ -0,0 +1,376 @@
+package build
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/app/dockerfile"
+	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+	df "github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+// targetBuilds are docker invocations that complete target
+type targetBuilds struct {
+	vertex api.Vertex
+	vertexBuilds
+}
+
+// targetTasks returns tasks that build targets of vertex graph and targets solved together by batch task.
+// Targets without dependencies are solved by single batch task, and targets that depend on them wait for batch task.
+// Targets with dependencies are not batched, since their dependencies may export files that they copy from build context
+func (service *buildService) targetTasks(
+	d df.Dockerfile,
+	v api.Vertex,
+	vars dockerfile.Vars,
+	rp runParams,
+) ([]task, map[string]targetBuilds, []targetBuilds, error) {
+	planner := newVertexPlanner()
+	planner.plan(v)
+
+	targets := make(map[string]targetBuilds, len(planner.vertexes))
+	var batch []targetBuilds
+	for _, planned := range planner.vertexes {
+		builds, err := service.vertexBuilds(planned.vertex, vars, rp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		t := targetBuilds{
+			vertex:       planned.vertex,
+			vertexBuilds: builds,
+		}
+		targets[t.vertex.Name] = t
+
+		if len(planned.deps) == 0 && canBatch(builds) {
+			batch = append(batch, t)
+		}
+	}
+
+	if len(batch) == 1 {
+		// There is no overhead to cut for single target
+		batch = nil
+	}
+
+	batched := maps.SetFromSlice(batch, func(t targetBuilds) string {
+		return t.vertex.Name
+	})
+
+	tasks := make([]task, 0, len(planner.vertexes)+1)
+	if len(batch) > 0 {
+		tasks = append(tasks, task{
+			name: dockerfile.BatchStageName,
+			run: func(ctx context.Context) error {
+				return service.buildBatch(ctx, d, batch, rp)
+			},
+		})
+	}
+
+	for _, planned := range planner.vertexes {
+		if batched.Has(planned.vertex.Name) {
+			continue
+		}
+
+		deps := maps.Set[string]{}
+		for _, dep := range planned.deps {
+			if batched.Has(dep) {
+				dep = dockerfile.BatchStageName
+			}
+			deps.Add(dep)
+		}
+
+		t := targets[planned.vertex.Name]
+		tasks = append(tasks, task{
+			name: t.vertex.Name,
+			deps: maps.ToSlice(deps, func(name string, _ struct{}) string {
+				return name
+			}),
+			run: func(ctx context.Context) error {
+				return service.buildTarget(ctx, d, t, rp)
+			},
+		})
+	}
+
+	return tasks, targets, batch, nil
+}
+
+// canBatch reports whether target may be solved together with other targets.
+// Images, tar and oci outputs need own exporter, so such targets are built separately
+func canBatch(builds vertexBuilds) bool {
+	if maybe.Valid(builds.image) {
+		return false
+	}
+
+	for _, b := range builds.builds {
+		if maybe.Valid(b.Output) && maybe.Just(b.Output).Type != docker.LocalOutput {
+			return false
+		}
+	}
+	return true
+}
+
+// batchSolve is build of batch stage for single set of platforms
+type batchSolve struct {
+	dockerfile df.Dockerfile // Dockerfile of targets with batch stage
+	params     docker.BuildParams
+	outputs    []batchOutput
+}
+
+// batchOutput is output of target which is exported within batch stage and then moved into destination
+type batchOutput struct {
+	target string
+	stage  string // Output stage, which is also directory in export of batch stage
+	dest   string
+}
+
+// newBatchSolves returns solve of batch for each set of platforms, since outputs are exported per platform
+func newBatchSolves(d df.Dockerfile, batch []targetBuilds, rp runParams) []batchSolve {
+	type platformBuilds struct {
+		platforms []string
+		targets   []string // Targets without outputs
+		outputs   []batchOutput
+	}
+
+	var groups []*platformBuilds
+	byPlatforms := map[string]*platformBuilds{}
+	for _, t := range batch {
+		for _, b := range t.builds {
+			key := strings.Join(b.Platforms, ",")
+			group, ok := byPlatforms[key]
+			if !ok {
+				group = &platformBuilds{platforms: b.Platforms}
+				byPlatforms[key] = group
+				groups = append(groups, group)
+			}
+
+			if !maybe.Valid(b.Output) {
+				group.targets = append(group.targets, b.Target)
+				continue
+			}
+			group.outputs = append(group.outputs, batchOutput{
+				target: t.vertex.Name,
+				stage:  b.Target,
+				dest:   maybe.Just(b.Output).Dest,
+			})
+		}
+	}
+
+	return slices.Map(groups, func(group *platformBuilds) batchSolve {
+		batchStage := dockerfile.BatchStage(group.targets, slices.Map(group.outputs, func(o batchOutput) string {
+			return o.stage
+		}))
+
+		stages := make([]df.Stage, 0, len(d.Stages)+1)
+		stages = append(stages, d.Stages...)
+		stages = append(stages, batchStage)
+
+		// Cache of batch is exported under own scope, since batch solves stages of several targets
+		scope := dockerfile.BatchStageName
+		if len(groups) > 1 {
+			scope += "-" + strings.Join(group.platforms, "-")
+		}
+
+		return batchSolve{
+			dockerfile: df.Dockerfile{
+				SyntaxHeader: d.SyntaxHeader,
+				Stages:       stages,
+			},
+			params: docker.BuildParams{
+				Target:       dockerfile.BatchStageName,
+				Platforms:    group.platforms,
+				SSHAgents:    rp.sshAgents,
+				Secrets:      rp.secrets,
+				Entitlements: rp.entitlements,
+				RemoteCache:  scopeRemoteCache(rp.remoteCache, scope),
+			},
+			outputs: group.outputs,
+		}
+	})
+}
+
+// buildBatch solves targets of batch together and moves their outputs into destinations
+func (service *buildService) buildBatch(ctx context.Context, d df.Dockerfile, batch []targetBuilds, rp runParams) error {
+	names := slices.Map(batch, func(t targetBuilds) string {
+		return t.vertex.Name
+	})
+
+	return rp.builds.runBatch(ctx, reporter.TargetKind, names, func(ctx context.Context) (batchResult, error) {
+		res := batchResult{
+			cached: maps.SetFromSlice(names, func(name string) string {
+				return name
+			}),
+		}
+
+		for _, solve := range newBatchSolves(d, batch, rp) {
+			executedStages, err := service.solveBatch(ctx, solve)
+			if err != nil {
+				return batchResult{failed: batchFailure(err, batch)}, err
+			}
+
+			for _, t := range batch {
+				for stage := range vertexStages(t.vertex, maps.Set[string]{}) {
+					if executedStages.Has(stage) {
+						res.cached.Remove(t.vertex.Name)
+						break
+					}
+				}
+			}
+		}
+
+		return res, nil
+	})
+}
+
+func (service *buildService) solveBatch(ctx context.Context, solve batchSolve) (maps.Set[string], error) {
+	service.reporter.Debugf("dockerfile of batch:\n%s\n", solve.dockerfile.Format())
+
+	exportDir, err := os.MkdirTemp("", "brewkit-batch-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create dir for batch export")
+	}
+	defer os.RemoveAll(exportDir)
+
+	params := solve.params
+	params.Output = maybe.NewJust(docker.Output{
+		Type: docker.LocalOutput,
+		Dest: exportDir,
+	})
+
+	result, err := service.dockerClient.Build(ctx, solve.dockerfile, params)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range solve.outputs {
+		err = moveDir(filepath.Join(exportDir, o.stage), o.dest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to export output of %s target", o.target)
+		}
+
+		service.reporter.OutputExported(o.target, o.dest)
+	}
+
+	return result.ExecutedStages, nil
+}
+
+// batchFailure finds target of failed instruction, it is reported as failure of target. Other targets of batch are canceled
+func batchFailure(err error, batch []targetBuilds) maybe.Maybe[string] {
+	var requestErr *docker.RequestError
+	if !errors.As(err, &requestErr) {
+		return maybe.NewNone[string]()
+	}
+
+	for _, t := range batch {
+		stages := vertexStages(t.vertex, maps.Set[string]{})
+		if stages.Has(requestErr.Stage) {
+			requestErr.Target = t.vertex.Name
+			return maybe.NewJust(t.vertex.Name)
+		}
+	}
+
+	requestErr.Target = strings.Join(slices.Map(batch, func(t targetBuilds) string {
+		return t.vertex.Name
+	}), ", ")
+	return maybe.NewNone[string]()
+}
+
+// vertexStages returns stages that are solved to build vertex: its own stage with output stages and stages it is based on
+func vertexStages(v api.Vertex, stages maps.Set[string]) maps.Set[string] {
+	if stages.Has(v.Name) {
+		return stages
+	}
+	stages.Add(v.Name)
+
+	if maybe.Valid(v.From) {
+		stages = vertexStages(*maybe.Just(v.From), stages)
+	}
+
+	if maybe.Valid(v.Stage) {
+		stage := maybe.Just(v.Stage)
+		for i := range stage.Outputs {
+			stages.Add(dockerfile.OutputStage(v.Name, i))
+		}
+
+		for _, source := range stage.Sources() {
+			source.MapLeft(func(sourceV *api.Vertex) {
+				stages = vertexStages(*sourceV, stages)
+			})
+		}
+	}
+
+	return stages
+}
+
+// moveDir moves content of src into dst and keeps existing files of dst, like local exporter of BuildKit does
+func moveDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == src && errors.Is(err, fs.ErrNotExist) {
+				// Nothing is exported when artifact of output is empty directory
+				return os.MkdirAll(dst, 0o755)
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		if os.Rename(p, target) == nil {
+			return nil
+		}
+		// Export dir may be on another device
+		return copyFile(p, target, info)
+	})
+}
+
+func copyFile(src, dst string, info fs.FileInfo) error {
+	err := os.Remove(dst)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err2 := os.Readlink(src)
+		if err2 != nil {
+			return err2
+		}
+		return os.Symlink(link, dst)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
@@ -0,0 +1,270 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/app/dockerfile"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	df "github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+func TestTargetTasksBatchTargetsWithoutDependencies(t *testing.T) {
+	gen := testVertex("gen", api.Stage{Outputs: []api.Output{{Artifact: "/gen", Local: "./gen", Type: api.LocalOutput}}})
+	lint := testVertex("lint", api.Stage{})
+	image := testVertex("image", api.Stage{Image: maybe.NewJust(api.Image{Tags: []string{"app"}})})
+	// build copies generated files from context, so it is built after batch
+	build := testVertex("build", api.Stage{Outputs: []api.Output{{Artifact: "/bin", Local: "./bin", Type: api.LocalOutput}}})
+	build.DependsOn = []api.Vertex{gen}
+	all := api.Vertex{
+		Name:      "all",
+		DependsOn: []api.Vertex{gen, lint, image, build},
+	}
+
+	for _, jobs := range []int{1, 4} {
+		tasks, _, batch, err := (&buildService{}).targetTasks(df.Dockerfile{}, all, dockerfile.Vars{}, runParams{jobs: jobs})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		batchNames := make([]string, 0, len(batch))
+		for _, b := range batch {
+			batchNames = append(batchNames, b.vertex.Name)
+		}
+		sort.Strings(batchNames)
+		if strings.Join(batchNames, ",") != "gen,lint" {
+			t.Errorf("jobs %d: expected gen and lint in batch, got %v", jobs, batchNames)
+		}
+
+		deps := map[string]string{}
+		for _, task := range tasks {
+			deps[task.name] = strings.Join(task.deps, ",")
+		}
+		expected := map[string]string{
+			dockerfile.BatchStageName: "",
+			"image":                   "",
+			"build":                   dockerfile.BatchStageName,
+		}
+		if len(deps) != len(expected) {
+			t.Errorf("jobs %d: expected tasks %v, got %v", jobs, expected, deps)
+		}
+		for name, dep := range expected {
+			if actual, ok := deps[name]; !ok || actual != dep {
+				t.Errorf("jobs %d: expected task %s with deps %q, got %q", jobs, name, dep, actual)
+			}
+		}
+	}
+}
+
+func TestBatchSolveExportsRemoteCache(t *testing.T) {
+	batch := []targetBuilds{
+		{vertex: api.Vertex{Name: "a"}, vertexBuilds: vertexBuilds{builds: []docker.BuildParams{{Target: "a"}}}},
+		{vertex: api.Vertex{Name: "b"}, vertexBuilds: vertexBuilds{builds: []docker.BuildParams{{
+			Target: "b-out-0",
+			Output: maybe.NewJust(docker.Output{Type: docker.LocalOutput, Dest: "./out"}),
+		}}}},
+	}
+	rp := runParams{
+		remoteCache: []docker.RemoteCache{{Type: docker.RegistryCache, Ref: "registry/cache", Mode: "max"}},
+	}
+
+	solves := newBatchSolves(df.Dockerfile{}, batch, rp)
+	if len(solves) != 1 {
+		t.Fatalf("expected single solve, got %d", len(solves))
+	}
+
+	remoteCache := solves[0].params.RemoteCache
+	if len(remoteCache) != 1 || remoteCache[0].Ref != "registry/cache:"+dockerfile.BatchStageName {
+		t.Errorf("expected cache scoped by batch, got %+v", remoteCache)
+	}
+	if len(solves[0].outputs) != 1 || solves[0].outputs[0].stage != "b-out-0" {
+		t.Errorf("expected output of b exported by batch, got %+v", solves[0].outputs)
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	testCases := []struct {
+		name     string
+		src      map[string]string // Files of exported dir, empty means that nothing is exported
+		dst      map[string]string // Existing files of destination
+		expected map[string]string
+	}{
+		{
+			name:     "rename into new destination",
+			src:      map[string]string{"bin/app": "app", "README": "readme"},
+			expected: map[string]string{"bin/app": "app", "README": "readme"},
+		},
+		{
+			name:     "merge into existing destination",
+			src:      map[string]string{"bin/app": "new app"},
+			dst:      map[string]string{"bin/app": "old app", "bin/tool": "tool"},
+			expected: map[string]string{"bin/app": "new app", "bin/tool": "tool"},
+		},
+		{
+			name:     "nothing exported",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "export")
+			dst := filepath.Join(t.TempDir(), "out")
+			writeFiles(t, src, tc.src)
+			writeFiles(t, dst, tc.dst)
+
+			err := moveDir(src, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := readFiles(t, dst)
+			if len(actual) != len(tc.expected) {
+				t.Errorf("expected files %v, got %v", tc.expected, actual)
+			}
+			for name, content := range tc.expected {
+				if actual[name] != content {
+					t.Errorf("expected %s with %q, got %q", name, content, actual[name])
+				}
+			}
+		})
+	}
+}
+
+func TestMoveDirKeepsSymlinks(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "export")
+	dst := filepath.Join(t.TempDir(), "out")
+	writeFiles(t, src, map[string]string{"lib/libapp.so.1": "lib"})
+	err := os.Symlink("libapp.so.1", filepath.Join(src, "lib", "libapp.so"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = moveDir(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSymlink(t, filepath.Join(dst, "lib", "libapp.so"), "libapp.so.1")
+}
+
+// copyFile is fallback of moveDir when export dir and destination are on different devices
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "app")
+	err := os.WriteFile(src, []byte("app"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "copied-app")
+	err = os.WriteFile(dst, []byte("old app"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = copyFile(src, dst, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "app" {
+		t.Errorf("expected replaced content %q, got %q", "app", data)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstInfo.Mode().Perm() != 0o755 {
+		t.Errorf("expected mode of source %v, got %v", os.FileMode(0o755), dstInfo.Mode().Perm())
+	}
+
+	link := filepath.Join(dir, "link")
+	err = os.Symlink("app", link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkInfo, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copiedLink := filepath.Join(dir, "copied-link")
+	err = copyFile(link, copiedLink, linkInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSymlink(t, copiedLink, "app")
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(p), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns content of regular files in dir by relative path
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	res := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		res[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func assertSymlink(t *testing.T, p, expected string) {
+	t.Helper()
+	link, err := os.Readlink(p)
+	if err != nil {
+		t.Fatalf("expected symlink %s: %v", p, err)
+	}
+	if link != expected {
+		t.Errorf("expected %s linked to %q, got %q", p, expected, link)
+	}
+}
+
+func testVertex(name string, stage api.Stage) api.Vertex {
+	stage.From = "alpine"
+	stage.Command = maybe.NewJust("true")
+	return api.Vertex{
+		Name:  name,
+		Stage: maybe.NewJust(stage),
+	}
+}
@@ -0,0 +1,56 @@
+package build
+
+import (
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/common/either"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+// listEntitlements returns entitlements required by vertex and vars, so builder is asked only for used privileges
+func listEntitlements(v api.Vertex, vars []api.Var) []docker.Entitlement {
+	hostNetwork := vertexUsesHostNetwork(v)
+	for _, v := range vars {
+		hostNetwork = hostNetwork || isHostNetwork(v.Network) || sourcesUseHostNetwork(v.Sources())
+	}
+
+	if hostNetwork {
+		return []docker.Entitlement{docker.NetworkHostEntitlement}
+	}
+	return nil
+}
+
+func vertexUsesHostNetwork(v api.Vertex) bool {
+	if maybe.Valid(v.From) && vertexUsesHostNetwork(*maybe.Just(v.From)) {
+		return true
+	}
+
+	if maybe.Valid(v.Stage) {
+		stage := maybe.Just(v.Stage)
+		if isHostNetwork(stage.Network) || sourcesUseHostNetwork(stage.Sources()) {
+			return true
+		}
+	}
+
+	for _, childV := range v.DependsOn {
+		if vertexUsesHostNetwork(childV) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sourcesUseHostNetwork(sources []either.Either[*api.Vertex, string]) (res bool) {
+	for _, source := range sources {
+		source.
+			MapLeft(func(v *api.Vertex) {
+				res = res || vertexUsesHostNetwork(*v)
+			})
+	}
+	return res
+}
+
+func isHostNetwork(network maybe.Maybe[api.Network]) bool {
+	return maybe.Valid(network) && maybe.Just(network).Network == api.HostNetwork
+}
@@ -0,0 +1,175 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+)
+
+func newBuildReporter(r reporter.Reporter, tracer trace.Tracer) *buildReporter {
+	return &buildReporter{
+		reporter: r,
+		tracer:   tracer,
+		reported: maps.Set[reportKey]{},
+	}
+}
+
+// buildReporter measures and traces builds of targets and vars and reports their results
+type buildReporter struct {
+	reporter reporter.Reporter
+	tracer   trace.Tracer
+
+	mu       sync.Mutex
+	reported maps.Set[reportKey]
+}
+
+type reportKey struct {
+	kind reporter.Kind
+	name string
+}
+
+// run executes build of target or var in its own span and reports its result, build returns whether all instructions are cached
+func (r *buildReporter) run(
+	ctx context.Context,
+	kind reporter.Kind,
+	name string,
+	build func(ctx context.Context) (cached bool, err error),
+) error {
+	r.reporter.Started(kind, name)
+
+	ctx, span := r.tracer.Start(ctx, fmt.Sprintf("%s %s", kind, name), trace.WithAttributes(
+		kindAttr.String(string(kind)),
+		nameAttr.String(name),
+	))
+
+	start := time.Now()
+	cached, err := build(ctx)
+
+	r.finish(span, kind, name, time.Since(start), cached, err)
+	return err
+}
+
+// batchResult describes builds solved together.
+// Failed is build of failed instruction, other builds of batch are canceled. All builds are failed when it is unknown
+type batchResult struct {
+	cached maps.Set[string] // Builds which instructions are all taken from cache
+	failed maybe.Maybe[string]
+}
+
+// runBatch executes builds of targets or vars solved together and reports result of each of them
+func (r *buildReporter) runBatch(
+	ctx context.Context,
+	kind reporter.Kind,
+	names []string,
+	build func(ctx context.Context) (batchResult, error),
+) error {
+	ctx, batchSpan := r.tracer.Start(ctx, "batch", trace.WithAttributes(
+		kindAttr.String(string(kind)),
+		namesAttr.StringSlice(names),
+	))
+
+	spans := slices.Map(names, func(name string) trace.Span {
+		r.reporter.Started(kind, name)
+
+		_, span := r.tracer.Start(ctx, fmt.Sprintf("%s %s", kind, name), trace.WithAttributes(
+			kindAttr.String(string(kind)),
+			nameAttr.String(name),
+		))
+		return span
+	})
+
+	start := time.Now()
+	res, err := build(ctx)
+	duration := time.Since(start)
+
+	for i, name := range names {
+		buildErr := err
+		if err != nil && maybe.Valid(res.failed) && maybe.Just(res.failed) != name {
+			buildErr = errors.WithStack(context.Canceled)
+		}
+		r.finish(spans[i], kind, name, duration, res.cached.Has(name), buildErr)
+	}
+	endSpan(batchSpan, err)
+
+	return err
+}
+
+func (r *buildReporter) finish(span trace.Span, kind reporter.Kind, name string, duration time.Duration, cached bool, err error) {
+	report := reporter.Report{
+		Name:     name,
+		Kind:     kind,
+		Duration: duration,
+	}
+
+	switch {
+	case err == nil && cached:
+		report.Status = reporter.CachedStatus
+	case err == nil:
+		report.Status = reporter.ExecutedStatus
+	case errors.Is(err, context.Canceled):
+		// Build canceled due to failure of other build
+		report.Status = reporter.SkippedStatus
+	default:
+		report.Status = reporter.FailedStatus
+		report.Failure = maybe.NewJust(mapFailure(err))
+	}
+
+	span.SetAttributes(
+		statusAttr.String(string(report.Status)),
+		cachedAttr.Bool(report.Status == reporter.CachedStatus),
+	)
+	endSpan(span, err)
+
+	r.report(report)
+}
+
+// skipNotReported reports builds that are not started as skipped
+func (r *buildReporter) skipNotReported(kind reporter.Kind, names []string) {
+	for _, name := range names {
+		r.mu.Lock()
+		reported := r.reported.Has(reportKey{kind: kind, name: name})
+		r.mu.Unlock()
+
+		if !reported {
+			r.report(reporter.Report{
+				Name:   name,
+				Kind:   kind,
+				Status: reporter.SkippedStatus,
+			})
+		}
+	}
+}
+
+func (r *buildReporter) report(report reporter.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reported.Add(reportKey{kind: report.Kind, name: report.Name})
+
+	r.reporter.Report(report)
+}
+
+func mapFailure(err error) reporter.Failure {
+	failure := reporter.Failure{
+		Error: err.Error(),
+	}
+
+	var requestErr *docker.RequestError
+	if errors.As(err, &requestErr) && requestErr.Instruction != "" {
+		failure.Instruction = maybe.NewJust(requestErr.Instruction)
+		failure.ExitCode = requestErr.ExitCode
+		failure.LogTail = requestErr.LogTail
+	}
+
+	return failure
+}
@@ -0,0 +1,146 @@
+package build
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/common/maps"
+)
+
+// task is a unit of work that scheduler executes once all dependencies are completed
+type task struct {
+	name string
+	deps []string // Names of tasks that should be completed before task starts
+	run  func(ctx context.Context) error
+}
+
+// runTasks executes independent tasks concurrently, but no more than jobs at the same time.
+// The first failed task cancels running tasks and prevents pending tasks from starting
+func runTasks(ctx context.Context, tasks []task, jobs int) error {
+	s, err := newScheduler(tasks, jobs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		task task
+		err  error
+	}
+
+	var (
+		results = make(chan result)
+		running int
+		failErr error
+	)
+
+	for {
+		// Failed or canceled run does not start pending tasks
+		if failErr == nil && ctx.Err() == nil {
+			for _, t := range s.next() {
+				running++
+				go func(t task) {
+					results <- result{task: t, err: t.run(ctx)}
+				}(t)
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		if res.err != nil {
+			if failErr == nil {
+				failErr = res.err
+				cancel() // Cancel siblings on first failure
+			}
+			continue
+		}
+		s.complete(res.task)
+	}
+
+	if failErr != nil {
+		return failErr
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if !s.done() {
+		return errors.New("logic error: tasks have cyclic dependencies")
+	}
+	return nil
+}
+
+// scheduler selects tasks which dependencies are completed, so that no more than jobs tasks are running
+type scheduler struct {
+	tasks     []task
+	jobs      int
+	running   int
+	started   maps.Set[string]
+	completed maps.Set[string]
+}
+
+func newScheduler(tasks []task, jobs int) (*scheduler, error) {
+	if jobs < 1 {
+		return nil, errors.Errorf("jobs count should be positive, got %d", jobs)
+	}
+
+	names := maps.SetFromSlice(tasks, func(t task) string {
+		return t.name
+	})
+	for _, t := range tasks {
+		for _, dep := range t.deps {
+			if !names.Has(dep) {
+				return nil, errors.Errorf("logic error: task %s depends on unknown task %s", t.name, dep)
+			}
+		}
+	}
+
+	return &scheduler{
+		tasks:     tasks,
+		jobs:      jobs,
+		started:   maps.Set[string]{},
+		completed: maps.Set[string]{},
+	}, nil
+}
+
+// next marks ready tasks as started and returns them
+func (s *scheduler) next() []task {
+	var res []task
+	for _, t := range s.tasks {
+		if s.running == s.jobs {
+			break
+		}
+		if s.started.Has(t.name) || !s.ready(t) {
+			continue
+		}
+
+		s.started.Add(t.name)
+		s.running++
+		res = append(res, t)
+	}
+	return res
+}
+
+func (s *scheduler) complete(t task) {
+	s.completed.Add(t.name)
+	s.running--
+}
+
+func (s *scheduler) done() bool {
+	return len(s.completed) == len(s.tasks)
+}
+
+func (s *scheduler) ready(t task) bool {
+	for _, dep := range t.deps {
+		if !s.completed.Has(dep) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package build
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunTasksStartsTaskOnceItsDependenciesCompleted(t *testing.T) {
+	// long1 -> long2 is slow branch, short -> afterShort should not wait for long2
+	longStarted := make(chan struct{})
+	releaseLong := make(chan struct{})
+	afterShortDone := make(chan struct{})
+
+	tasks := []task{
+		{name: "long1", run: func(ctx context.Context) error {
+			close(longStarted)
+			<-releaseLong
+			return nil
+		}},
+		{name: "long2", deps: []string{"long1"}, run: noopRun},
+		{name: "short", run: noopRun},
+		{name: "afterShort", deps: []string{"short"}, run: func(ctx context.Context) error {
+			close(afterShortDone)
+			return nil
+		}},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runTasks(context.Background(), tasks, 2)
+	}()
+
+	<-longStarted
+	<-afterShortDone
+	close(releaseLong)
+
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func noopRun(context.Context) error {
+	return nil
+}
@@ -3,15 +3,23 @@ package build
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ispringtech/brewkit/internal/backend/api"
 	"github.com/ispringtech/brewkit/internal/backend/app/docker"
 	"github.com/ispringtech/brewkit/internal/backend/app/dockerfile"
 	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
 	"github.com/ispringtech/brewkit/internal/backend/app/ssh"
+	"github.com/ispringtech/brewkit/internal/common/either"
 	"github.com/ispringtech/brewkit/internal/common/maps"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/common/slices"
@@ -27,12 +35,14 @@ func NewBuildService(
 	dockerfileImage string,
 	sshAgentProvider ssh.AgentProvider,
 	backendReporter reporter.Reporter,
+	tracer trace.Tracer,
 ) Service {
 	return &buildService{
 		dockerClient:     dockerClient,
 		dockerfileImage:  dockerfileImage,
 		sshAgentProvider: sshAgentProvider,
 		reporter:         backendReporter,
+		tracer:           tracer,
 	}
 }
 
@@ -41,6 +51,7 @@ type buildService struct {
 	dockerfileImage  string
 	sshAgentProvider ssh.AgentProvider
 	reporter         reporter.Reporter
+	tracer           trace.Tracer
 }
 
 func (service *buildService) Build(
@@ -49,132 +60,609 @@ func (service *buildService) Build(
 	vars []api.Var,
 	secretsSrc []api.SecretSrc,
 	params api.BuildParams,
+) error {
+	service.reporter.BuildStarted(v.Name)
+
+	ctx, span := service.tracer.Start(ctx, "build", trace.WithAttributes(targetAttr.String(v.Name)))
+	err := service.build(ctx, v, vars, secretsSrc, params)
+	endSpan(span, err)
+	service.reporter.BuildFinished(err)
+
+	summaryErr := service.reporter.Summary()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(summaryErr, "failed to report build summary")
+}
+
+func (service *buildService) build(
+	ctx context.Context,
+	v api.Vertex,
+	vars []api.Var,
+	secretsSrc []api.SecretSrc,
+	params api.BuildParams,
 ) error {
 	err := service.prePullImages(ctx, v, vars, params.ForcePull)
 	if err != nil {
 		return err
 	}
 
-	varsMap, err := service.calculateVars(ctx, vars)
+	sshAgents, err := service.listSSHAgents(v, vars, params.SSHAgents)
 	if err != nil {
 		return err
 	}
 
-	return service.buildVertex(ctx, v, varsMap, secretsSrc)
+	builds := newBuildReporter(service.reporter, service.tracer)
+	rp := runParams{
+		secrets:      mapSecrets(secretsSrc),
+		remoteCache:  slices.Map(params.RemoteCache, mapRemoteCache),
+		jobs:         params.Jobs,
+		varJobs:      varJobs(params.Jobs),
+		platforms:    params.Platforms,
+		entitlements: listEntitlements(v, vars),
+		sshAgents:    sshAgents,
+		builds:       builds,
+	}
+
+	varsMap, err := service.calculateVars(ctx, vars, rp)
+	if err == nil {
+		err = service.buildVertex(ctx, v, varsMap, rp)
+	}
+
+	// Report vars and targets that are not built due to failure
+	builds.skipNotReported(reporter.VarKind, slices.Map(vars, func(v api.Var) string {
+		return v.Name
+	}))
+	planner := newVertexPlanner()
+	planner.plan(v)
+	builds.skipNotReported(reporter.TargetKind, slices.Map(planner.vertexes, func(p plannedVertex) string {
+		return p.vertex.Name
+	}))
+
+	return err
 }
 
-func (service *buildService) calculateVars(ctx context.Context, vars []api.Var) (dockerfile.Vars, error) {
-	if len(vars) == 0 {
-		return nil, nil
+// runParams are common for all builds of vars and targets
+type runParams struct {
+	secrets      []docker.SecretData
+	remoteCache  []docker.RemoteCache
+	jobs         int      // Max count of concurrent builds of targets
+	varJobs      int      // Max count of concurrent calculations of vars
+	platforms    []string // Target platforms, vars are always calculated for host platform
+	entitlements []docker.Entitlement
+	sshAgents    []docker.SSHAgent // Only agents used by vertex and vars
+	builds       *buildReporter
+}
+
+func (service *buildService) Plan(
+	ctx context.Context,
+	v api.Vertex,
+	vars []api.Var,
+	secretsSrc []api.SecretSrc,
+	params api.PlanParams,
+) (api.Plan, error) {
+	ctx, span := service.tracer.Start(ctx, "plan", trace.WithAttributes(targetAttr.String(v.Name)))
+	defer span.End()
+
+	sshAgents, err := service.listSSHAgents(v, vars, params.SSHAgents)
+	if err != nil {
+		return api.Plan{}, err
+	}
+
+	rp := runParams{
+		secrets:      mapSecrets(secretsSrc),
+		remoteCache:  slices.Map(params.RemoteCache, mapRemoteCache),
+		jobs:         params.Jobs,
+		varJobs:      varJobs(params.Jobs),
+		platforms:    params.Platforms,
+		entitlements: listEntitlements(v, vars),
+		sshAgents:    sshAgents,
+		builds:       newBuildReporter(service.reporter, service.tracer),
+	}
+
+	varsMap := dockerfile.Vars{}
+	if params.StubVars {
+		for _, v := range vars {
+			varsMap[v.Name] = fmt.Sprintf("<%s>", v.Name)
+		}
+	} else {
+		varsMap, err = service.calculateVars(ctx, vars, rp)
+		if err != nil {
+			return api.Plan{}, err
+		}
 	}
 
-	d, err := dockerfile.NewVarGenerator(service.dockerfileImage).GenerateDockerfile(vars)
+	planVars := slices.Map(vars, func(v api.Var) api.PlanVar {
+		return api.PlanVar{
+			Name:  v.Name,
+			Value: varsMap[v.Name],
+			Stub:  params.StubVars,
+		}
+	})
+
+	d, err := dockerfile.NewTargetGenerator(v, varsMap, service.dockerfileImage).GenerateDockerfile()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to generate dockerfile for variables")
+		return api.Plan{}, err
 	}
 
-	res := map[string]string{}
+	stepParams := planStepParams{
+		allow: slices.Map(rp.entitlements, func(e docker.Entitlement) string {
+			return string(e)
+		}),
+		secrets: slices.Map(rp.secrets, func(s docker.SecretData) string {
+			return s.ID
+		}),
+		sshAgents: slices.Map(rp.sshAgents, func(a docker.SSHAgent) api.SSHAgentSrc {
+			return api.SSHAgentSrc{
+				ID:         a.ID,
+				SourcePath: a.Path,
+			}
+		}),
+	}
 
-	for _, v := range vars {
-		// Check if context closed before running Value
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+	tasks, targets, batch, err := service.targetTasks(d, v, varsMap, rp)
+	if err != nil {
+		return api.Plan{}, err
+	}
+
+	s, err := newScheduler(tasks, rp.jobs)
+	if err != nil {
+		return api.Plan{}, err
+	}
+
+	var steps []api.PlanStep
+	// Steps are listed as if all started builds are completed at the same time
+	for !s.done() {
+		started := s.next()
+		if len(started) == 0 {
+			return api.Plan{}, errors.New("logic error: targets have cyclic dependencies")
+		}
+
+		for _, t := range started {
+			if t.name == dockerfile.BatchStageName && len(batch) > 0 {
+				steps = append(steps, planBatchSteps(d, batch, rp, stepParams)...)
+				continue
+			}
+			steps = append(steps, planTargetSteps(d, targets[t.name], stepParams)...)
+		}
+
+		for _, t := range started {
+			s.complete(t)
 		}
+	}
+
+	return api.Plan{
+		Vars:  planVars,
+		Steps: steps,
+	}, nil
+}
+
+// planStepParams are common for all steps of plan
+type planStepParams struct {
+	allow     []string
+	secrets   []string
+	sshAgents []api.SSHAgentSrc
+}
 
-		data, err2 := service.dockerClient.Value(ctx, d, docker.ValueParams{
-			Var:      v.Name,
-			SSHAgent: maybe.NewJust(service.sshAgentProvider.Default()),
-			UseCache: false, // Disable cache for retrieving variable value
+// planTargetSteps returns steps of target built by own docker invocations
+func planTargetSteps(d df.Dockerfile, t targetBuilds, params planStepParams) []api.PlanStep {
+	var steps []api.PlanStep
+	if maybe.Valid(t.image) {
+		imageParams := maybe.Just(t.image)
+		steps = append(steps, api.PlanStep{
+			Vertex:    t.vertex.Name,
+			Target:    imageParams.Target,
+			Platforms: imageParams.Platforms,
+			Allow:     params.allow,
+			Secrets:   params.secrets,
+			SSHAgents: params.sshAgents,
+			Image: maybe.NewJust(api.Image{
+				Tags:   imageParams.Tags,
+				Labels: imageParams.Labels,
+				Push:   imageParams.Push,
+				Load:   imageParams.Load,
+			}),
+			Dockerfile: d.Format(),
 		})
-		if err2 != nil {
-			return nil, errors.Wrapf(err2, "failed to calculate %s var", v.Name)
+	}
+
+	for _, buildParams := range t.builds {
+		steps = append(steps, api.PlanStep{
+			Vertex:    t.vertex.Name,
+			Target:    buildParams.Target,
+			Platforms: buildParams.Platforms,
+			Allow:     params.allow,
+			Output: maybe.Map(buildParams.Output, func(o docker.Output) string {
+				return o.String()
+			}),
+			Secrets:    params.secrets,
+			SSHAgents:  params.sshAgents,
+			Dockerfile: d.Format(),
+		})
+	}
+
+	return steps
+}
+
+// planBatchSteps returns steps of targets solved together, outputs are exported into temporary dir and then moved
+func planBatchSteps(
+	d df.Dockerfile,
+	batch []targetBuilds,
+	rp runParams,
+	params planStepParams,
+) []api.PlanStep {
+	names := slices.Map(batch, func(t targetBuilds) string {
+		return t.vertex.Name
+	})
+
+	return slices.Map(newBatchSolves(d, batch, rp), func(solve batchSolve) api.PlanStep {
+		return api.PlanStep{
+			Vertex:    strings.Join(names, ", "),
+			Target:    solve.params.Target,
+			Platforms: solve.params.Platforms,
+			Allow:     params.allow,
+			Output: maybe.NewJust(docker.Output{
+				Type: docker.LocalOutput,
+				Dest: "<temporary dir>",
+			}.String()),
+			Secrets:   params.secrets,
+			SSHAgents: params.sshAgents,
+			Batch: slices.Map(solve.outputs, func(o batchOutput) api.BatchOutput {
+				return api.BatchOutput{
+					Stage: o.stage,
+					Output: docker.Output{
+						Type: docker.LocalOutput,
+						Dest: o.dest,
+					}.String(),
+				}
+			}),
+			Dockerfile: solve.dockerfile.Format(),
 		}
+	})
+}
 
-		res[v.Name] = string(data)
+func (service *buildService) calculateVars(
+	ctx context.Context,
+	vars []api.Var,
+	rp runParams,
+) (_ dockerfile.Vars, err error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	ctx, span := service.startPhase(ctx, "vars")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	var (
+		mu  sync.Mutex
+		res = dockerfile.Vars{}
+	)
+
+	tasks := slices.Map(vars, func(v api.Var) task {
+		return task{
+			name: v.Name,
+			deps: v.DependsOn,
+			run: func(ctx context.Context) error {
+				mu.Lock()
+				// Var depends only on already calculated vars
+				d, err2 := dockerfile.NewVarGenerator(service.dockerfileImage, res).GenerateDockerfile(v)
+				mu.Unlock()
+				if err2 != nil {
+					return errors.Wrapf(err2, "failed to generate dockerfile for %s var", v.Name)
+				}
+
+				service.reporter.Debugf("dockerfile for %s var:\n%s\n", v.Name, d.Format())
+
+				var value string
+				err2 = rp.builds.run(ctx, reporter.VarKind, v.Name, func(ctx context.Context) (cached bool, err error) {
+					value, cached, err = service.calculateVar(ctx, d, v, rp)
+					return cached, err
+				})
+				if err2 != nil {
+					return errors.Wrapf(err2, "failed to calculate %s var", v.Name)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				res[v.Name] = value
+
+				return nil
+			},
+		}
+	})
+
+	err = runTasks(ctx, tasks, rp.varJobs)
+	if err != nil {
+		return nil, err
 	}
 
 	return res, nil
 }
 
+// varJobs returns count of concurrently calculated vars. Vars are usually small commands, like git describe,
+// so they are calculated concurrently even when targets are built one by one
+func varJobs(jobs int) int {
+	if n := runtime.NumCPU(); n > jobs {
+		return n
+	}
+	return jobs
+}
+
+func (service *buildService) calculateVar(
+	ctx context.Context,
+	d df.Dockerfile,
+	v api.Var,
+	rp runParams,
+) (value string, cached bool, err error) {
+	remoteCache := scopeRemoteCache(rp.remoteCache, fmt.Sprintf("var-%s", v.Name))
+
+	if !v.UseCache {
+		data, err := service.dockerClient.Value(ctx, d, docker.ValueParams{
+			Var:          v.Name,
+			SSHAgents:    rp.sshAgents,
+			Secrets:      rp.secrets,
+			Entitlements: rp.entitlements,
+			UseCache:     false, // Disable cache for retrieving variable value
+			RemoteCache:  remoteCache,
+		})
+		return string(data), false, withTarget(err, v.Name)
+	}
+
+	// Export value of cached var, since there is no command output on cache hit
+	outputDir, err := os.MkdirTemp("", "brewkit-var-")
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to create dir for var value")
+	}
+	defer os.RemoveAll(outputDir)
+
+	result, err := service.dockerClient.Build(ctx, d, docker.BuildParams{
+		Target:       dockerfile.VarOutputStage(v),
+		SSHAgents:    rp.sshAgents,
+		Secrets:      rp.secrets,
+		Entitlements: rp.entitlements,
+		Output: maybe.NewJust(docker.Output{
+			Type: docker.LocalOutput,
+			Dest: outputDir,
+		}),
+		RemoteCache: remoteCache,
+	})
+	if err != nil {
+		return "", false, withTarget(err, v.Name)
+	}
+
+	data, err := os.ReadFile(path.Join(outputDir, dockerfile.VarValueFile))
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to read exported var value")
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), result.Cached, nil
+}
+
 func (service *buildService) buildVertex(
 	ctx context.Context,
 	v api.Vertex,
 	vars dockerfile.Vars,
-	secretsSrc []api.SecretSrc,
+	rp runParams,
 ) error {
+	_, span := service.startPhase(ctx, "dockerfile")
 	d, err := dockerfile.NewTargetGenerator(v, vars, service.dockerfileImage).GenerateDockerfile()
+	endSpan(span, err)
 	if err != nil {
 		return err
 	}
 
 	service.reporter.Debugf("dockerfile:\n%s\n", d.Format())
 
-	executedVertexes := maps.Set[string]{}
+	ctx, span = service.startPhase(ctx, "targets")
+	err = service.buildTargets(ctx, d, v, vars, rp)
+	endSpan(span, err)
+	return err
+}
 
-	secrets := slices.Map(secretsSrc, func(s api.SecretSrc) docker.SecretData {
-		return docker.SecretData{
-			ID:   s.ID,
-			Path: s.SourcePath,
-		}
-	})
+// buildTargets builds targets once their dependencies are built, independent targets are solved together by batch
+func (service *buildService) buildTargets(
+	ctx context.Context,
+	d df.Dockerfile,
+	v api.Vertex,
+	vars dockerfile.Vars,
+	rp runParams,
+) error {
+	tasks, _, _, err := service.targetTasks(d, v, vars, rp)
+	if err != nil {
+		return err
+	}
 
-	var recursiveBuild func(ctx context.Context, v api.Vertex) error
-	recursiveBuild = func(ctx context.Context, v api.Vertex) error {
-		if executedVertexes.Has(v.Name) {
-			// Skip already executed stages
-			return nil
-		}
+	return runTasks(ctx, tasks, rp.jobs)
+}
 
-		if maybe.Valid(v.From) && shouldExplicitRunFrom(*maybe.Just(v.From)) {
-			err2 := recursiveBuild(ctx, *maybe.Just(v.From))
-			if err2 != nil {
-				return err2
+func (service *buildService) buildTarget(ctx context.Context, d df.Dockerfile, t targetBuilds, rp runParams) error {
+	return rp.builds.run(ctx, reporter.TargetKind, t.vertex.Name, func(ctx context.Context) (bool, error) {
+		cached := true
+
+		if maybe.Valid(t.image) {
+			trace.SpanFromContext(ctx).SetAttributes(imageTagsAttr.StringSlice(maybe.Just(t.image).Tags))
+
+			result, err := service.dockerClient.BuildImage(ctx, d, maybe.Just(t.image))
+			if err != nil {
+				return false, withTarget(err, t.vertex.Name)
 			}
+			cached = cached && result.Cached
 		}
 
-		for _, childVertex := range v.DependsOn {
-			err2 := recursiveBuild(ctx, childVertex)
-			if err2 != nil {
-				return err2
+		for _, buildParams := range t.builds {
+			result, err := service.dockerClient.Build(ctx, d, buildParams)
+			if err != nil {
+				return false, withTarget(err, t.vertex.Name)
+			}
+			cached = cached && result.Cached
+
+			if maybe.Valid(buildParams.Output) {
+				service.reporter.OutputExported(t.vertex.Name, maybe.Just(buildParams.Output).Dest)
 			}
 		}
 
-		if !maybe.Valid(v.Stage) {
-			return nil
+		return cached, nil
+	})
+}
+
+// vertexBuilds are docker invocations that complete vertex
+type vertexBuilds struct {
+	image  maybe.Maybe[docker.BuildImageParams]
+	builds []docker.BuildParams
+}
+
+func (service *buildService) vertexBuilds(
+	v api.Vertex,
+	vars dockerfile.Vars,
+	rp runParams,
+) (vertexBuilds, error) {
+	var res vertexBuilds
+
+	remoteCache := scopeRemoteCache(rp.remoteCache, v.Name)
+
+	stage := maybe.Just(v.Stage)
+	if maybe.Valid(stage.Image) {
+		image, err := expandImage(maybe.Just(stage.Image), vars)
+		if err != nil {
+			return vertexBuilds{}, errors.Wrapf(err, "failed to expand image of %s target", v.Name)
 		}
 
-		executedVertexes.Add(v.Name)
+		res.image = maybe.NewJust(docker.BuildImageParams{
+			Target:       v.Name,
+			Platforms:    rp.platforms,
+			SSHAgents:    rp.sshAgents,
+			Secrets:      rp.secrets,
+			Entitlements: rp.entitlements,
+			RemoteCache:  remoteCache,
+			Tags:         image.Tags,
+			Labels:       image.Labels,
+			Push:         image.Push,
+			Load:         image.Load,
+		})
+	}
+
+	for i, o := range stage.Outputs {
+		local, err := vars.Expand(o.Local)
+		if err != nil {
+			return vertexBuilds{}, errors.Wrapf(err, "failed to expand output of %s target", v.Name)
+		}
 
-		targetName := v.Name
-		var output maybe.Maybe[string]
+		output := docker.Output{
+			Type: docker.OutputType(o.Type),
+			Dest: local,
+		}
 
-		stage := maybe.Just(v.Stage)
-		if maybe.Valid(stage.Output) {
-			o := maybe.Just(stage.Output)
+		// Execute output stage to save artifacts
+		params := docker.BuildParams{
+			Target:       dockerfile.OutputStage(v.Name, i),
+			Platforms:    rp.platforms,
+			SSHAgents:    rp.sshAgents,
+			Output:       maybe.NewJust(output),
+			Secrets:      rp.secrets,
+			Entitlements: rp.entitlements,
+			RemoteCache:  remoteCache,
+		}
 
-			// Execute output stage to save artifacts
-			targetName = fmt.Sprintf("%s-out", v.Name)
-			output = maybe.NewJust(o.Local)
+		if len(rp.platforms) <= 1 {
+			res.builds = append(res.builds, params)
+			continue
 		}
 
-		// Check if context closed before running build
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		// Artifacts of each platform are exported separately, so they do not overwrite each other
+		for _, platform := range rp.platforms {
+			platformParams := params
+			platformParams.Platforms = []string{platform}
+			platformParams.Output = maybe.NewJust(platformOutput(output, platform))
+			res.builds = append(res.builds, platformParams)
 		}
+	}
 
-		return service.dockerClient.Build(ctx, d, docker.BuildParams{
-			Target:   targetName,
-			SSHAgent: maybe.NewJust(service.sshAgentProvider.Default()),
-			Output:   output,
-			Secrets:  secrets,
+	if len(stage.Outputs) == 0 && !maybe.Valid(stage.Image) {
+		// Image build already executes target
+		res.builds = append(res.builds, docker.BuildParams{
+			Target:       v.Name,
+			Platforms:    rp.platforms,
+			SSHAgents:    rp.sshAgents,
+			Secrets:      rp.secrets,
+			Entitlements: rp.entitlements,
+			RemoteCache:  remoteCache,
 		})
 	}
 
-	return recursiveBuild(ctx, v)
+	return res, nil
+}
+
+// platformOutput suffixes output destination with platform: ./bin -> ./bin-linux-amd64, ./app.tar -> ./app-linux-amd64.tar.
+// Destinations without name, like . or .., get platform subdirectory: . -> ./linux-amd64
+func platformOutput(output docker.Output, platform string) docker.Output {
+	platformName := strings.ReplaceAll(platform, "/", "-")
+
+	dest := path.Clean(output.Dest)
+	switch base := path.Base(dest); {
+	case base == "." || base == ".." || base == "/":
+		output.Dest = path.Join(dest, platformName)
+	case output.Type == docker.LocalOutput || path.Ext(base) == "":
+		output.Dest = dest + "-" + platformName
+	default:
+		ext := path.Ext(base)
+		output.Dest = strings.TrimSuffix(dest, ext) + "-" + platformName + ext
+	}
+	return output
+}
+
+func expandImage(image api.Image, vars dockerfile.Vars) (api.Image, error) {
+	tags, err := slices.MapErr(image.Tags, vars.Expand)
+	if err != nil {
+		return api.Image{}, err
+	}
+
+	labels := make(map[string]string, len(image.Labels))
+	for k, v := range image.Labels {
+		labels[k], err = vars.Expand(v)
+		if err != nil {
+			return api.Image{}, err
+		}
+	}
+
+	image.Tags = tags
+	image.Labels = labels
+	return image, nil
+}
+
+// withTarget reports failed build as failure of target or var instead of internal stage, e.g. output stage
+func withTarget(err error, name string) error {
+	var requestErr *docker.RequestError
+	if errors.As(err, &requestErr) {
+		requestErr.Target = name
+	}
+	return err
+}
+
+func mapRemoteCache(c api.RemoteCache) docker.RemoteCache {
+	return docker.RemoteCache{
+		Type:     docker.RemoteCacheType(c.Type),
+		Ref:      c.Ref,
+		Path:     c.Path,
+		Mode:     c.Mode,
+		ReadOnly: c.ReadOnly,
+	}
+}
+
+func scopeRemoteCache(remoteCache []docker.RemoteCache, scope string) []docker.RemoteCache {
+	return slices.Map(remoteCache, func(c docker.RemoteCache) docker.RemoteCache {
+		return c.Scoped(scope)
+	})
+}
+
+func mapSecrets(secretsSrc []api.SecretSrc) []docker.SecretData {
+	return slices.Map(secretsSrc, func(s api.SecretSrc) docker.SecretData {
+		return docker.SecretData{
+			ID:   s.ID,
+			Path: s.SourcePath,
+		}
+	})
 }
 
 func (service *buildService) prePullImages(
@@ -182,17 +670,28 @@ func (service *buildService) prePullImages(
 	v api.Vertex,
 	vars []api.Var,
 	forcePull bool,
-) error {
+) (err error) {
+	ctx, span := service.startPhase(ctx, "pre-pull")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	images := maps.Set[string]{}
 	images.Add(service.dockerfileImage)
 
 	images = service.listVertexImages(v, images)
 	images = service.listVarsImages(vars, images)
 
+	imagesSlice := maps.ToSlice(images, func(image string, _ struct{}) string {
+		return image
+	})
+	sort.Strings(imagesSlice)
+	span.SetAttributes(imagesAttr.StringSlice(imagesSlice))
+
 	if forcePull {
 		service.reporter.Logf("Force pull images\n")
-		for image := range images {
-			err2 := service.dockerClient.PullImage(ctx, image)
+		for _, image := range imagesSlice {
+			err2 := service.pullImage(ctx, image)
 			if err2 != nil {
 				return err2
 			}
@@ -200,9 +699,6 @@ func (service *buildService) prePullImages(
 		return nil
 	}
 
-	imagesSlice := maps.ToSlice(images, func(image string, _ struct{}) string {
-		return image
-	})
 	existingImages, err := service.dockerClient.ListImages(ctx, imagesSlice)
 	if err != nil {
 		return errors.Wrap(err, "failed to filter existing images")
@@ -218,7 +714,7 @@ func (service *buildService) prePullImages(
 
 	service.reporter.Logf("Absent images: %s\n", strings.Join(imagesToPull, " "))
 	for _, image := range imagesToPull {
-		err2 := service.dockerClient.PullImage(ctx, image)
+		err2 := service.pullImage(ctx, image)
 		if err2 != nil {
 			return err2
 		}
@@ -227,6 +723,19 @@ func (service *buildService) prePullImages(
 	return nil
 }
 
+func (service *buildService) pullImage(ctx context.Context, image string) error {
+	ctx, span := service.tracer.Start(ctx, "pull", trace.WithAttributes(imageAttr.String(image)))
+	start := time.Now()
+	err := service.dockerClient.PullImage(ctx, image)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	service.reporter.ImagePulled(image, time.Since(start))
+	return nil
+}
+
 func (service *buildService) listVertexImages(v api.Vertex, images maps.Set[string]) maps.Set[string] {
 	// Recursive walk to From stage
 	if maybe.Valid(v.From) {
@@ -243,24 +752,7 @@ func (service *buildService) listVertexImages(v api.Vertex, images maps.Set[stri
 	}
 
 	if maybe.Valid(v.Stage) {
-		copyDirs := maybe.Just(v.Stage).Copy
-
-		for _, c := range copyDirs {
-			if !maybe.Valid(c.From) {
-				// Skip vertexes with empty from
-				continue
-			}
-
-			maybe.Just(c.From).
-				MapLeft(func(copyV *api.Vertex) {
-					images = service.listVertexImages(*copyV, images)
-				}).
-				MapRight(func(image string) {
-					if image != df.Scratch && !images.Has(image) {
-						images.Add(image)
-					}
-				})
-		}
+		images = service.listSourcesImages(maybe.Just(v.Stage).Sources(), images)
 	}
 
 	for _, childVertex := range v.DependsOn {
@@ -276,18 +768,112 @@ func (service *buildService) listVarsImages(vars []api.Var, images maps.Set[stri
 		if !images.Has(image) {
 			images.Add(image)
 		}
+
+		images = service.listSourcesImages(v.Sources(), images)
+	}
+
+	return images
+}
+
+func (service *buildService) listSourcesImages(
+	sources []either.Either[*api.Vertex, string],
+	images maps.Set[string],
+) maps.Set[string] {
+	for _, source := range sources {
+		source.
+			MapLeft(func(sourceV *api.Vertex) {
+				images = service.listVertexImages(*sourceV, images)
+			}).
+			MapRight(func(image string) {
+				if image != df.Scratch && !images.Has(image) {
+					images.Add(image)
+				}
+			})
 	}
 
 	return images
 }
 
+func newVertexPlanner() *vertexPlanner {
+	return &vertexPlanner{
+		planned: map[string][]string{},
+	}
+}
+
+// vertexPlanner translates vertex graph into vertexes that are built by own docker invocations
+type vertexPlanner struct {
+	vertexes []plannedVertex     // In topological order
+	planned  map[string][]string // Vertex name to names of vertexes that complete vertex
+}
+
+type plannedVertex struct {
+	vertex api.Vertex
+	deps   []string // Names of vertexes that should be built before vertex
+}
+
+// plan walks through vertex edges and returns names of vertexes that should be built to consider vertex as built
+func (planner *vertexPlanner) plan(v api.Vertex) []string {
+	if taskNames, ok := planner.planned[v.Name]; ok {
+		// Skip already planned vertexes
+		return taskNames
+	}
+
+	deps := maps.Set[string]{}
+
+	if maybe.Valid(v.From) && shouldExplicitRunFrom(*maybe.Just(v.From)) {
+		addTaskNames(deps, planner.plan(*maybe.Just(v.From)))
+	}
+
+	if maybe.Valid(v.Stage) {
+		for _, source := range maybe.Just(v.Stage).Sources() {
+			source.
+				MapLeft(func(sourceV *api.Vertex) {
+					if shouldExplicitRunFrom(*sourceV) {
+						addTaskNames(deps, planner.plan(*sourceV))
+					}
+				})
+		}
+	}
+
+	for _, childVertex := range v.DependsOn {
+		addTaskNames(deps, planner.plan(childVertex))
+	}
+
+	depsSlice := maps.ToSlice(deps, func(name string, _ struct{}) string {
+		return name
+	})
+
+	if !maybe.Valid(v.Stage) {
+		// Vertex without stage is completed when all its dependencies are completed
+		planner.planned[v.Name] = depsSlice
+		return depsSlice
+	}
+
+	planner.vertexes = append(planner.vertexes, plannedVertex{
+		vertex: v,
+		deps:   depsSlice,
+	})
+
+	taskNames := []string{v.Name}
+	planner.planned[v.Name] = taskNames
+
+	return taskNames
+}
+
+func addTaskNames(deps maps.Set[string], taskNames []string) {
+	for _, name := range taskNames {
+		deps.Add(name)
+	}
+}
+
 func shouldExplicitRunFrom(v api.Vertex) bool {
-	var hasOutput bool
+	var hasOutput, hasImage bool
 	if maybe.Valid(v.Stage) {
-		hasOutput = maybe.Valid(maybe.Just(v.Stage).Output)
+		hasOutput = len(maybe.Just(v.Stage).Outputs) > 0
+		hasImage = maybe.Valid(maybe.Just(v.Stage).Image)
 	}
 
 	hasDependsOn := len(v.DependsOn) > 0
 
-	return hasOutput || hasDependsOn
+	return hasOutput || hasImage || hasDependsOn
 }
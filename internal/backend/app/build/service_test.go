@@ -0,0 +1,74 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/app/dockerfile"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+func TestPlatformOutput(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   docker.Output
+		expected string
+	}{
+		{name: "local dir", output: docker.Output{Type: docker.LocalOutput, Dest: "./bin"}, expected: "bin-linux-amd64"},
+		{name: "local dir with trailing slash", output: docker.Output{Type: docker.LocalOutput, Dest: "./bin/"}, expected: "bin-linux-amd64"},
+		{name: "local dir with dot", output: docker.Output{Type: docker.LocalOutput, Dest: "./app.d"}, expected: "app.d-linux-amd64"},
+		{name: "current dir", output: docker.Output{Type: docker.LocalOutput, Dest: "."}, expected: "linux-amd64"},
+		{name: "parent dir", output: docker.Output{Type: docker.LocalOutput, Dest: ".."}, expected: "../linux-amd64"},
+		{name: "tar", output: docker.Output{Type: docker.TarOutput, Dest: "./app.tar"}, expected: "app-linux-amd64.tar"},
+		{name: "tar without extension", output: docker.Output{Type: docker.TarOutput, Dest: "./app"}, expected: "app-linux-amd64"},
+		{name: "oci", output: docker.Output{Type: docker.OCIOutput, Dest: "out/image.oci.tar"}, expected: "out/image.oci-linux-amd64.tar"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := platformOutput(tc.output, "linux/amd64")
+			if res.Dest != tc.expected {
+				t.Errorf("expected dest %q, got %q", tc.expected, res.Dest)
+			}
+			if res.Type != tc.output.Type {
+				t.Errorf("expected type %q, got %q", tc.output.Type, res.Type)
+			}
+		})
+	}
+}
+
+func TestVertexBuildsExportOutputPerPlatform(t *testing.T) {
+	testCases := []struct {
+		name      string
+		platforms []string
+		expected  []string // Platforms and destinations of builds
+	}{
+		{name: "host platform", expected: []string{" ./bin"}},
+		{name: "single platform", platforms: []string{"linux/arm64"}, expected: []string{"linux/arm64 ./bin"}},
+		{
+			name:      "several platforms",
+			platforms: []string{"linux/amd64", "linux/arm64"},
+			expected:  []string{"linux/amd64 bin-linux-amd64", "linux/arm64 bin-linux-arm64"},
+		},
+	}
+
+	v := testVertex("app", api.Stage{Outputs: []api.Output{{Artifact: "/app/bin", Local: "./bin", Type: api.LocalOutput}}})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builds, err := (&buildService{}).vertexBuilds(v, dockerfile.Vars{}, runParams{platforms: tc.platforms})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := make([]string, 0, len(builds.builds))
+			for _, b := range builds.builds {
+				actual = append(actual, strings.Join(b.Platforms, ",")+" "+maybe.Just(b.Output).Dest)
+			}
+			if strings.Join(actual, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("expected builds %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package build
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/common/either"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+)
+
+// listSSHAgents returns ssh agents used by vertex and vars, so agents are resolved and forwarded only when needed.
+// Agents from host config take precedence over default agent
+func (service *buildService) listSSHAgents(
+	v api.Vertex,
+	vars []api.Var,
+	agentsSrc []api.SSHAgentSrc,
+) ([]docker.SSHAgent, error) {
+	usages := sshAgentUsages{}
+	for _, v := range vars {
+		usages.add(v.SSH, fmt.Sprintf("%s var", v.Name))
+		usages.addSources(v.Sources())
+	}
+	usages.addVertex(v)
+
+	ids := make([]string, 0, len(usages))
+	for id := range usages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return slices.MapErr(ids, func(id string) (docker.SSHAgent, error) {
+		agentSrc := slices.Find(agentsSrc, func(a api.SSHAgentSrc) bool {
+			return a.ID == id
+		})
+		if maybe.Valid(agentSrc) {
+			return docker.SSHAgent{
+				ID:   id,
+				Path: maybe.Just(agentSrc).SourcePath,
+			}, nil
+		}
+
+		if id != api.DefaultSSHAgent {
+			return docker.SSHAgent{}, errors.Errorf("ssh agent %s used by %s is not found in config", id, usages[id])
+		}
+
+		socket, err := service.sshAgentProvider.Default()
+		if err != nil {
+			return docker.SSHAgent{}, errors.Wrapf(err, "%s uses default ssh agent", usages[id])
+		}
+		return docker.SSHAgent{
+			ID:   id,
+			Path: socket,
+		}, nil
+	})
+}
+
+// sshAgentUsages maps ID of ssh agent to first target or var that uses it
+type sshAgentUsages map[string]string
+
+func (usages sshAgentUsages) add(ssh maybe.Maybe[api.SSH], user string) {
+	if !maybe.Valid(ssh) {
+		return
+	}
+
+	id := maybe.Just(ssh).ID
+	if _, ok := usages[id]; !ok {
+		usages[id] = user
+	}
+}
+
+func (usages sshAgentUsages) addVertex(v api.Vertex) {
+	if maybe.Valid(v.From) {
+		usages.addVertex(*maybe.Just(v.From))
+	}
+
+	if maybe.Valid(v.Stage) {
+		stage := maybe.Just(v.Stage)
+		usages.add(stage.SSH, fmt.Sprintf("%s target", v.Name))
+		usages.addSources(stage.Sources())
+	}
+
+	for _, childV := range v.DependsOn {
+		usages.addVertex(childV)
+	}
+}
+
+func (usages sshAgentUsages) addSources(sources []either.Either[*api.Vertex, string]) {
+	for _, source := range sources {
+		source.
+			MapLeft(func(v *api.Vertex) {
+				usages.addVertex(*v)
+			})
+	}
+}
@@ -0,0 +1,36 @@
+package build
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of build spans
+const (
+	targetAttr    = attribute.Key("brewkit.target")
+	kindAttr      = attribute.Key("brewkit.kind")
+	nameAttr      = attribute.Key("brewkit.name")
+	namesAttr     = attribute.Key("brewkit.names")
+	statusAttr    = attribute.Key("brewkit.status")
+	cachedAttr    = attribute.Key("brewkit.cached")
+	imageAttr     = attribute.Key("brewkit.image")
+	imagesAttr    = attribute.Key("brewkit.images")
+	imageTagsAttr = attribute.Key("brewkit.image.tags")
+)
+
+// startPhase starts span of build phase: pre-pull of images, calculation of vars, generation of Dockerfile or build of targets
+func (service *buildService) startPhase(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return service.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan marks span as failed when err occurred and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,29 +2,83 @@ package docker
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/ispringtech/brewkit/internal/common/maps"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/dockerfile"
 )
 
 type BuildParams struct {
-	Target   string
-	SSHAgent maybe.Maybe[string]
-	Secrets  []SecretData
-	Output   maybe.Maybe[string]
+	Target       string
+	Platforms    []string // Target platforms, host platform is used when empty
+	SSHAgents    []SSHAgent
+	Secrets      []SecretData
+	Output       maybe.Maybe[Output]
+	RemoteCache  []RemoteCache
+	Entitlements []Entitlement
+}
+
+type OutputType string
+
+const (
+	LocalOutput OutputType = "local"
+	TarOutput   OutputType = "tar"
+	OCIOutput   OutputType = "oci"
+)
+
+type Output struct {
+	Type OutputType
+	Dest string // Directory for local output, file for tar and oci
+}
+
+func (o Output) String() string {
+	return fmt.Sprintf("type=%s,dest=%s", o.Type, o.Dest)
 }
 
 type ValueParams struct {
-	Var      string
-	SSHAgent maybe.Maybe[string]
-	Secrets  []SecretData
-	UseCache bool
+	Var          string
+	SSHAgents    []SSHAgent
+	Secrets      []SecretData
+	UseCache     bool
+	RemoteCache  []RemoteCache
+	Entitlements []Entitlement
 }
 
+type BuildImageParams struct {
+	Target       string
+	Platforms    []string // Platforms of multi-platform image
+	SSHAgents    []SSHAgent
+	Secrets      []SecretData
+	RemoteCache  []RemoteCache
+	Entitlements []Entitlement
+	Tags         []string
+	Labels       map[string]string
+	Push         bool // Push image to registry
+	Load         bool // Load image into local docker daemon
+}
+
+// Entitlement is privilege that build requests from builder
+type Entitlement string
+
+const (
+	NetworkHostEntitlement Entitlement = "network.host" // Allows RUN --network=host
+)
+
 type ClearCacheParams struct {
 	All bool
 }
 
+// SSHAgent is ssh agent socket or private key file forwarded to build by ID
+type SSHAgent struct {
+	ID   string
+	Path string
+}
+
+func (a SSHAgent) String() string {
+	return fmt.Sprintf("%s=%s", a.ID, a.Path)
+}
+
 type SecretData struct {
 	ID   string
 	Path string
@@ -34,12 +88,18 @@ type Image struct {
 	Img string // Image with repository and tag
 }
 
+// BuildResult describes completed build
+type BuildResult struct {
+	Cached         bool             // All instructions of target are taken from cache
+	ExecutedStages maps.Set[string] // Stages with instructions that are not taken from cache
+}
+
 type Client interface {
-	Build(ctx context.Context, dockerfile dockerfile.Dockerfile, params BuildParams) error
+	Build(ctx context.Context, dockerfile dockerfile.Dockerfile, params BuildParams) (BuildResult, error)
 	Value(ctx context.Context, dockerfile dockerfile.Dockerfile, params ValueParams) ([]byte, error)
 	PullImage(ctx context.Context, img string) error
 	ListImages(ctx context.Context, images []string) ([]Image, error)
-	BuildImage(ctx context.Context, dockerfilePath string) error
+	BuildImage(ctx context.Context, dockerfile dockerfile.Dockerfile, params BuildImageParams) (BuildResult, error)
 
 	ClearCache(ctx context.Context, params ClearCacheParams) error
 }
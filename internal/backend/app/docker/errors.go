@@ -2,18 +2,67 @@ package docker
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/ispringtech/brewkit/internal/common/maybe"
 )
 
+// RequestError describes failed build of target or var.
+// Instruction, ExitCode and LogTail are known when failed instruction is found in build progress
 type RequestError struct {
-	Output string
-	Code   int
+	Target      string           // Target or var of failed build
+	Instruction string           // Failed Dockerfile instruction, e.g. [gobuild 2/3] RUN go build ./...
+	Stage       string           // Stage of failed instruction
+	ExitCode    maybe.Maybe[int] // Exit code of failed command
+	LogTail     []string         // Last lines of failed instruction output
+	Output      string           // Output of docker client
+	Code        int              // Exit code of docker client
 }
 
 func (e RequestError) Error() string {
-	msg := fmt.Sprintf("request to docker client failed: code %d\n", e.Code)
-	if e.Output != "" {
-		msg += fmt.Sprintf("%s\n", e.Output)
+	if e.Instruction == "" {
+		msg := fmt.Sprintf("request to docker client failed: code %d\n", e.Code)
+		if e.Target != "" {
+			msg = fmt.Sprintf("%s failed: %s", e.Target, msg)
+		}
+		if e.Output != "" {
+			msg += fmt.Sprintf("%s\n", e.Output)
+		}
+		return msg
+	}
+
+	msg := fmt.Sprintf("%s failed at %s", e.Target, e.Instruction)
+	if maybe.Valid(e.ExitCode) {
+		msg += fmt.Sprintf(": exit code %d", maybe.Just(e.ExitCode))
+	}
+	msg += "\n"
+	for _, line := range e.LogTail {
+		msg += fmt.Sprintf("  %s\n", line)
 	}
 
 	return msg
 }
+
+// EntitlementRefusedError is returned when builder is not configured to grant requested entitlement
+type EntitlementRefusedError struct {
+	Entitlement Entitlement
+}
+
+func (e EntitlementRefusedError) Error() string {
+	return fmt.Sprintf(
+		"builder refused %[1]s entitlement, start buildkitd with --allow-insecure-entitlement %[1]s "+
+			"or create builder with `docker buildx create --buildkitd-flags '--allow-insecure-entitlement %[1]s'`",
+		e.Entitlement,
+	)
+}
+
+// CheckEntitlements returns EntitlementRefusedError when build output reports that one of requested entitlements is refused
+func CheckEntitlements(output string, entitlements []Entitlement) error {
+	for _, e := range entitlements {
+		// buildkitd reports "granting entitlement network.host is not allowed" or "network.host is not allowed"
+		if strings.Contains(output, fmt.Sprintf("%s is not allowed", e)) {
+			return EntitlementRefusedError{Entitlement: e}
+		}
+	}
+	return nil
+}
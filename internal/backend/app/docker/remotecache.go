@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"path"
+	"strings"
+)
+
+type RemoteCacheType string
+
+const (
+	RegistryCache RemoteCacheType = "registry"
+	LocalCache    RemoteCacheType = "local"
+	InlineCache   RemoteCacheType = "inline"
+)
+
+// RemoteCache describes backend to import build cache from and export to
+type RemoteCache struct {
+	Type     RemoteCacheType
+	Ref      string // Image reference for registry and inline cache
+	Path     string // Directory for local cache
+	Mode     string // Cache export mode: min or max
+	ReadOnly bool   // Only import cache
+}
+
+// CacheEntry is cache import or export in terms of buildkit
+type CacheEntry struct {
+	Type  string
+	Attrs map[string]string
+}
+
+// Scoped returns cache that does not overlap with caches of other scopes.
+// Each build exports cache only for its own stages, so builds of different targets would overwrite shared cache
+func (c RemoteCache) Scoped(scope string) RemoteCache {
+	scope = sanitizeTag(scope)
+
+	switch c.Type {
+	case RegistryCache:
+		if hasTag(c.Ref) {
+			c.Ref += "-" + scope
+		} else {
+			c.Ref += ":" + scope
+		}
+	case LocalCache:
+		c.Path = path.Join(c.Path, scope)
+	case InlineCache:
+		// Inline cache is stored in image itself
+	}
+
+	return c
+}
+
+// Import returns cache import, if cache can be imported
+func (c RemoteCache) Import() (CacheEntry, bool) {
+	switch c.Type {
+	case RegistryCache:
+		return CacheEntry{Type: string(RegistryCache), Attrs: map[string]string{"ref": c.Ref}}, true
+	case LocalCache:
+		return CacheEntry{Type: string(LocalCache), Attrs: map[string]string{"src": c.Path}}, true
+	case InlineCache:
+		if c.Ref == "" {
+			return CacheEntry{}, false
+		}
+		// Inline cache imported from image in registry
+		return CacheEntry{Type: string(RegistryCache), Attrs: map[string]string{"ref": c.Ref}}, true
+	default:
+		return CacheEntry{}, false
+	}
+}
+
+// Export returns cache export, if cache can be exported
+func (c RemoteCache) Export() (CacheEntry, bool) {
+	if c.ReadOnly {
+		return CacheEntry{}, false
+	}
+
+	switch c.Type {
+	case RegistryCache:
+		return CacheEntry{Type: string(RegistryCache), Attrs: map[string]string{"ref": c.Ref, "mode": c.Mode}}, true
+	case LocalCache:
+		return CacheEntry{Type: string(LocalCache), Attrs: map[string]string{"dest": c.Path, "mode": c.Mode}}, true
+	case InlineCache:
+		return CacheEntry{Type: string(InlineCache), Attrs: map[string]string{}}, true
+	default:
+		return CacheEntry{}, false
+	}
+}
+
+func hasTag(ref string) bool {
+	// Colon before last slash separates registry port
+	return strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/")
+}
+
+func sanitizeTag(s string) string {
+	const maxTagLength = 128
+
+	res := []byte(s)
+	for i, c := range res {
+		isValid := c == '_' || c == '.' || c == '-' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		if !isValid {
+			res[i] = '-'
+		}
+	}
+
+	if len(res) > maxTagLength {
+		res = res[:maxTagLength]
+	}
+
+	return string(res)
+}
@@ -2,18 +2,18 @@ package dockerfile
 
 import (
 	"fmt"
-	"os"
+	"path"
+	"strconv"
 
 	"github.com/pkg/errors"
 
 	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/common/either"
 	"github.com/ispringtech/brewkit/internal/common/maps"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/dockerfile"
 )
 
-type Vars map[string]string
-
 type TargetGenerator interface {
 	GenerateDockerfile() (dockerfile.Dockerfile, error)
 }
@@ -58,7 +58,7 @@ func (generator targetGenerator) stagesForTarget(v api.Vertex) ([]dockerfile.Sta
 
 	if maybe.Valid(v.Stage) {
 		stage := maybe.Just(v.Stage)
-		s, err := generator.stagesForCopy(stage)
+		s, err := generator.stagesForSources(stage.Sources())
 		if err != nil {
 			return nil, err
 		}
@@ -82,19 +82,15 @@ func (generator targetGenerator) stagesForTarget(v api.Vertex) ([]dockerfile.Sta
 	return stages, nil
 }
 
-func (generator targetGenerator) stagesForCopy(stage api.Stage) ([]dockerfile.Stage, error) {
+// stagesForSources generates stages of targets from which files are copied or cache is seeded
+func (generator targetGenerator) stagesForSources(sources []either.Either[*api.Vertex, string]) ([]dockerfile.Stage, error) {
 	var stages []dockerfile.Stage
-	for _, c := range stage.Copy {
-		if !maybe.Valid(c.From) {
-			// Skip images with empty from
-			continue
-		}
-
+	for _, source := range sources {
 		var (
 			s   []dockerfile.Stage
 			err error
 		)
-		maybe.Just(c.From).
+		source.
 			MapLeft(func(l *api.Vertex) {
 				s, err = generator.stagesForTarget(*l)
 				if err != nil {
@@ -123,26 +119,18 @@ func (generator targetGenerator) stages(name string, stage api.Stage) ([]dockerf
 	stages := []dockerfile.Stage{
 		{
 			From:         stage.From,
+			Platform:     stage.Platform,
 			As:           maybe.NewJust(name),
 			Instructions: instructions,
 		},
 	}
 
-	if maybe.Valid(stage.Output) {
-		output := maybe.Just(stage.Output)
-
-		const pwd = "."
-		stages = append(stages, dockerfile.Stage{
-			From: dockerfile.Scratch,
-			As:   maybe.NewJust(fmt.Sprintf("%s-out", name)),
-			Instructions: []dockerfile.Instruction{
-				dockerfile.Copy{
-					Src:  output.Artifact,
-					Dst:  pwd,
-					From: maybe.NewJust(name),
-				},
-			},
-		})
+	for i, output := range stage.Outputs {
+		s, err2 := generator.outputStage(name, i, output)
+		if err2 != nil {
+			return nil, err2
+		}
+		stages = append(stages, s)
 	}
 
 	generator.generatedStages[name] = struct{}{}
@@ -150,35 +138,89 @@ func (generator targetGenerator) stages(name string, stage api.Stage) ([]dockerf
 	return stages, nil
 }
 
+// OutputStage returns name of stage that exports i-th output of target
+func OutputStage(name string, i int) string {
+	return fmt.Sprintf("%s-out-%d", name, i)
+}
+
+// BatchStageName is name of stage that completes several targets in one build
+const BatchStageName = "brewkit-batch"
+
+// BatchStage returns scratch stage that depends on targets, so BuildKit solves them in one request.
+// Output stages are copied into directories named after them, other targets are only executed
+func BatchStage(targets, outputStages []string) dockerfile.Stage {
+	instructions := make([]dockerfile.Instruction, 0, len(targets)+len(outputStages))
+	for _, target := range targets {
+		instructions = append(instructions, dockerfile.Copy{
+			// Wildcard that matches nothing makes target executed without copying files
+			Src:  "/brewkit-batch-nothing*",
+			Dst:  "/",
+			From: maybe.NewJust(target),
+		})
+	}
+	for _, stage := range outputStages {
+		instructions = append(instructions, dockerfile.Copy{
+			Src:  "/",
+			Dst:  path.Join("/", stage) + "/",
+			From: maybe.NewJust(stage),
+		})
+	}
+
+	return dockerfile.Stage{
+		From:         dockerfile.Scratch,
+		As:           maybe.NewJust(BatchStageName),
+		Instructions: instructions,
+	}
+}
+
+func (generator targetGenerator) outputStage(name string, i int, output api.Output) (dockerfile.Stage, error) {
+	e := expander{vars: generator.vars}
+
+	artifact := e.expand(output.Artifact)
+
+	// Artifact is copied into root of scratch stage, so exported files are placed directly into destination
+	dst := "/"
+	if maybe.Valid(output.Rename) {
+		dst = path.Join("/", e.expand(maybe.Just(output.Rename)))
+	}
+
+	if e.err != nil {
+		return dockerfile.Stage{}, errors.Wrap(e.err, "failed to expand output")
+	}
+
+	return dockerfile.Stage{
+		From: dockerfile.Scratch,
+		As:   maybe.NewJust(OutputStage(name, i)),
+		Instructions: []dockerfile.Instruction{
+			dockerfile.Copy{
+				Src:  artifact,
+				Dst:  dst,
+				From: maybe.NewJust(name),
+			},
+		},
+	}, nil
+}
+
 func (generator targetGenerator) instructionsForStage(stage api.Stage) ([]dockerfile.Instruction, error) {
+	e := expander{vars: generator.vars}
+
 	//nolint:prealloc
 	var instructions []dockerfile.Instruction
 
-	instructions = append(instructions, dockerfile.Workdir(stage.WorkDir))
+	instructions = append(instructions, dockerfile.Workdir(e.expand(stage.WorkDir)))
 
 	for k, v := range stage.Env {
 		instructions = append(instructions, dockerfile.Env{
 			K: k,
-			V: v,
+			V: e.expand(v),
 		})
 	}
 
 	for _, c := range stage.Copy {
-		var from maybe.Maybe[string]
-		if maybe.Valid(c.From) {
-			maybe.Just(c.From).
-				MapLeft(func(v *api.Vertex) {
-					from = maybe.NewJust(v.Name)
-				}).
-				MapRight(func(image string) {
-					from = maybe.NewJust(image)
-				})
-		}
-
 		instructions = append(instructions, dockerfile.Copy{
-			Src:  c.Src,
-			Dst:  c.Dst,
-			From: from,
+			Src:  e.expand(c.Src),
+			Dst:  e.expand(c.Dst),
+			From: copyFrom(c),
 		})
 	}
 
@@ -186,22 +228,32 @@ func (generator targetGenerator) instructionsForStage(stage api.Stage) ([]docker
 	var mounts []dockerfile.Mount
 
 	for _, cache := range stage.Cache {
-		mounts = append(mounts, dockerfile.MountCache{
-			ID:     maybe.NewJust(cache.ID),
-			Target: cache.Path,
+		mounts = append(mounts, cacheMount(cache, &e))
+	}
+
+	for _, tmpfs := range stage.Tmpfs {
+		mounts = append(mounts, tmpfsMount(tmpfs, &e))
+	}
+
+	for _, m := range stage.Mounts {
+		mounts = append(mounts, dockerfile.MountBind{
+			Target: e.expand(m.Dst),
+			Source: maybe.NewJust(e.expand(m.Src)),
+			From:   sourceName(m.From),
 		})
 	}
 
 	for _, secret := range stage.Secrets {
 		mounts = append(mounts, dockerfile.MountSecret{
 			ID:       maybe.NewJust(secret.ID),
-			Target:   maybe.NewJust(secret.MountPath),
+			Target:   maybe.NewJust(e.expand(secret.MountPath)),
 			Required: maybe.NewJust(true), // make error if secret unavailable
 		})
 	}
 
 	if maybe.Valid(stage.SSH) {
 		mounts = append(mounts, dockerfile.MountSSH{
+			ID:       maybe.NewJust(maybe.Just(stage.SSH).ID),
 			Required: maybe.NewJust(true), // make error if ssh key unavailable
 		})
 	}
@@ -212,9 +264,7 @@ func (generator targetGenerator) instructionsForStage(stage api.Stage) ([]docker
 			network = maybe.Just(stage.Network).Network
 		}
 
-		command := generator.fillCommandWithVariables(maybe.Just(stage.Command))
-
-		command = generator.transformToHeredoc(command)
+		command := generator.transformToHeredoc(e.expand(maybe.Just(stage.Command)))
 
 		instructions = append(instructions, dockerfile.Run{
 			Mounts:  mounts,
@@ -223,13 +273,11 @@ func (generator targetGenerator) instructionsForStage(stage api.Stage) ([]docker
 		})
 	}
 
-	return instructions, nil
-}
+	if e.err != nil {
+		return nil, e.err
+	}
 
-func (generator targetGenerator) fillCommandWithVariables(command string) string {
-	return os.Expand(command, func(v string) string {
-		return generator.vars[v]
-	})
+	return instructions, nil
 }
 
 func (generator targetGenerator) transformToHeredoc(s string) string {
@@ -237,3 +285,54 @@ func (generator targetGenerator) transformToHeredoc(s string) string {
 
 	return fmt.Sprintf("<<%s\n%s\n%s", heredocHeader, s, heredocHeader)
 }
+
+// copyFrom returns name of stage or image from which Copy copies
+func copyFrom(c api.Copy) maybe.Maybe[string] {
+	return sourceName(c.From)
+}
+
+// sourceName returns name of stage or image
+func sourceName(source maybe.Maybe[either.Either[*api.Vertex, string]]) (name maybe.Maybe[string]) {
+	if maybe.Valid(source) {
+		maybe.Just(source).
+			MapLeft(func(v *api.Vertex) {
+				name = maybe.NewJust(v.Name)
+			}).
+			MapRight(func(image string) {
+				name = maybe.NewJust(image)
+			})
+	}
+	return name
+}
+
+func tmpfsMount(tmpfs api.Tmpfs, e *expander) dockerfile.MountTmpfs {
+	return dockerfile.MountTmpfs{
+		Target: e.expand(tmpfs.Path),
+		Size:   tmpfs.Size,
+	}
+}
+
+func cacheMount(cache api.Cache, e *expander) dockerfile.MountCache {
+	formatID := func(id int) string {
+		return strconv.Itoa(id)
+	}
+
+	var readOnly maybe.Maybe[bool]
+	if cache.ReadOnly {
+		readOnly = maybe.NewJust(true)
+	}
+
+	return dockerfile.MountCache{
+		ID:     maybe.NewJust(cache.ID),
+		Target: e.expand(cache.Path),
+		Sharing: maybe.Map(cache.Sharing, func(s api.CacheSharing) string {
+			return string(s)
+		}),
+		ReadOnly: readOnly,
+		From:     sourceName(cache.From),
+		Source:   maybe.Map(cache.Source, e.expand),
+		Mode:     cache.Mode,
+		UID:      maybe.Map(cache.UID, formatID),
+		GID:      maybe.Map(cache.GID, formatID),
+	}
+}
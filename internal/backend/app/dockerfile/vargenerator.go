@@ -2,58 +2,129 @@ package dockerfile
 
 import (
 	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
 
 	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/common/maps"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
-	"github.com/ispringtech/brewkit/internal/common/slices"
 	"github.com/ispringtech/brewkit/internal/dockerfile"
 )
 
+const (
+	// VarValueFile is file with value of cached var exported from var output stage
+	VarValueFile = ".brewkit-var-value"
+)
+
+var (
+	// varValuePath is writable for any user, so value is saved when var stage runs under non-root USER of image
+	varValuePath = path.Join("/tmp", VarValueFile)
+)
+
 type VarGenerator interface {
-	GenerateDockerfile(vars []api.Var) (dockerfile.Dockerfile, error)
+	GenerateDockerfile(v api.Var) (dockerfile.Dockerfile, error)
 }
 
-func NewVarGenerator(dockerfileImage string) VarGenerator {
-	return &varGenerator{dockerfileImage: dockerfileImage}
+// NewVarGenerator returns generator for var dockerfile, vars should contain values of vars on which var depends
+func NewVarGenerator(dockerfileImage string, vars Vars) VarGenerator {
+	return &varGenerator{
+		dockerfileImage: dockerfileImage,
+		vars:            vars,
+	}
 }
 
 type varGenerator struct {
 	dockerfileImage string
+	vars            Vars
 }
 
-func (generator varGenerator) GenerateDockerfile(vars []api.Var) (dockerfile.Dockerfile, error) {
+func (generator varGenerator) GenerateDockerfile(v api.Var) (dockerfile.Dockerfile, error) {
+	targets := targetGenerator{
+		dockerfileImage: generator.dockerfileImage,
+		vars:            generator.vars,
+		generatedStages: maps.Set[string]{},
+	}
+
+	// Generate stages for targets from which var copies
+	stages, err := targets.stagesForSources(v.Sources())
+	if err != nil {
+		return dockerfile.Dockerfile{}, err
+	}
+
+	varStages, err := generator.stagesForVar(v)
+	if err != nil {
+		return dockerfile.Dockerfile{}, err
+	}
+
+	stages = append(stages, varStages...)
+
 	return dockerfile.Dockerfile{
 		SyntaxHeader: dockerfile.Syntax(generator.dockerfileImage),
-		Stages:       slices.Map(vars, generator.stageForVar),
+		Stages:       stages,
 	}, nil
 }
 
-func (generator varGenerator) stageForVar(v api.Var) dockerfile.Stage {
-	return dockerfile.Stage{
-		From:         v.From,
-		As:           maybe.NewJust(v.Name),
-		Instructions: generator.instructionsForVar(v),
+// VarOutputStage returns name of stage that exports value of cached var
+func VarOutputStage(v api.Var) string {
+	return fmt.Sprintf("%s-out", v.Name)
+}
+
+func (generator varGenerator) stagesForVar(v api.Var) ([]dockerfile.Stage, error) {
+	instructions, err := generator.instructionsForVar(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate instructions for %s var", v.Name)
+	}
+
+	stages := []dockerfile.Stage{
+		{
+			From:         v.From,
+			Platform:     v.Platform,
+			As:           maybe.NewJust(v.Name),
+			Instructions: instructions,
+		},
+	}
+
+	if v.UseCache {
+		// Value of cached var can not be retrieved from command output, since command is not executed on cache hit.
+		// So value saved to file and exported
+		const pwd = "."
+		stages = append(stages, dockerfile.Stage{
+			From: dockerfile.Scratch,
+			As:   maybe.NewJust(VarOutputStage(v)),
+			Instructions: []dockerfile.Instruction{
+				dockerfile.Copy{
+					Src:  varValuePath,
+					Dst:  pwd,
+					From: maybe.NewJust(v.Name),
+				},
+			},
+		})
 	}
+
+	return stages, nil
 }
 
-func (generator varGenerator) instructionsForVar(v api.Var) []dockerfile.Instruction {
+func (generator varGenerator) instructionsForVar(v api.Var) ([]dockerfile.Instruction, error) {
+	e := expander{vars: generator.vars}
+
 	//nolint:prealloc
 	var instructions []dockerfile.Instruction
 
-	instructions = append(instructions, dockerfile.Workdir(v.WorkDir))
+	instructions = append(instructions, dockerfile.Workdir(e.expand(v.WorkDir)))
 
-	for k, v := range v.Env {
+	for k, value := range v.Env {
 		instructions = append(instructions, dockerfile.Env{
 			K: k,
-			V: v,
+			V: e.expand(value),
 		})
 	}
 
 	for _, c := range v.Copy {
 		instructions = append(instructions, dockerfile.Copy{
-			Src:  c.Src,
-			Dst:  c.Dst,
-			From: c.From,
+			Src:  e.expand(c.Src),
+			Dst:  e.expand(c.Dst),
+			From: copyFrom(c),
 		})
 	}
 
@@ -61,22 +132,24 @@ func (generator varGenerator) instructionsForVar(v api.Var) []dockerfile.Instruc
 	var mounts []dockerfile.Mount
 
 	for _, cache := range v.Cache {
-		mounts = append(mounts, dockerfile.MountCache{
-			ID:     maybe.NewJust(cache.ID),
-			Target: cache.Path,
-		})
+		mounts = append(mounts, cacheMount(cache, &e))
+	}
+
+	for _, tmpfs := range v.Tmpfs {
+		mounts = append(mounts, tmpfsMount(tmpfs, &e))
 	}
 
 	for _, secret := range v.Secrets {
 		mounts = append(mounts, dockerfile.MountSecret{
 			ID:       maybe.NewJust(secret.ID),
-			Target:   maybe.NewJust(secret.MountPath),
+			Target:   maybe.NewJust(e.expand(secret.MountPath)),
 			Required: maybe.NewJust(true), // make error if secret unavailable
 		})
 	}
 
 	if maybe.Valid(v.SSH) {
 		mounts = append(mounts, dockerfile.MountSSH{
+			ID:       maybe.NewJust(maybe.Just(v.SSH).ID),
 			Required: maybe.NewJust(true), // make error if ssh key unavailable
 		})
 	}
@@ -86,7 +159,12 @@ func (generator varGenerator) instructionsForVar(v api.Var) []dockerfile.Instruc
 		network = maybe.Just(v.Network).Network
 	}
 
-	command := generator.transformToHeredoc(v.Command)
+	command := e.expand(v.Command)
+	if v.UseCache {
+		command = generator.transformToHeredocWithOutputFile(command)
+	} else {
+		command = generator.transformToHeredoc(command)
+	}
 
 	instructions = append(instructions, dockerfile.Run{
 		Mounts:  mounts,
@@ -94,7 +172,11 @@ func (generator varGenerator) instructionsForVar(v api.Var) []dockerfile.Instruc
 		Command: command,
 	})
 
-	return instructions
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return instructions, nil
 }
 
 func (generator varGenerator) transformToHeredoc(s string) string {
@@ -102,3 +184,11 @@ func (generator varGenerator) transformToHeredoc(s string) string {
 
 	return fmt.Sprintf("<<%s\n%s\n%s", heredocHeader, s, heredocHeader)
 }
+
+// transformToHeredocWithOutputFile runs command in shell and saves its stdout to var value file
+func (generator varGenerator) transformToHeredocWithOutputFile(s string) string {
+	const heredocHeader = "EOF"
+
+	// Quoted heredoc header disables expansion of heredoc by shell that runs sh
+	return fmt.Sprintf("sh > %s <<'%s'\n%s\n%s", varValuePath, heredocHeader, s, heredocHeader)
+}
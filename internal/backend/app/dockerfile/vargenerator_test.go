@@ -0,0 +1,30 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+)
+
+func TestCachedVarSavesValueToUserWritablePath(t *testing.T) {
+	d, err := NewVarGenerator("dockerfile-image", Vars{}).GenerateDockerfile(api.Var{
+		Name:     "gitcommit",
+		From:     "alpine",
+		Command:  "git rev-parse HEAD",
+		UseCache: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatted := d.Format()
+	for _, expected := range []string{
+		"sh > /tmp/.brewkit-var-value <<'EOF'",
+		"COPY --from=gitcommit /tmp/.brewkit-var-value .",
+	} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("expected %q in dockerfile:\n%s", expected, formatted)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package dockerfile
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/common/expand"
+)
+
+// Vars maps var name to its value
+type Vars map[string]string
+
+// Expand replaces ${var} and $var references in s with values of vars and fails on undefined vars.
+// Use $$ for literal $
+func (vars Vars) Expand(s string) (string, error) {
+	return expand.Expand(s, func(name string) (string, error) {
+		value, ok := vars[name]
+		if !ok {
+			return "", errors.Errorf("reference to undefined var %s, use $$ for literal $", name)
+		}
+		return value, nil
+	})
+}
+
+// expander expands vars in series of strings and keeps the first error
+type expander struct {
+	vars Vars
+	err  error
+}
+
+func (e *expander) expand(s string) string {
+	if e.err != nil {
+		return ""
+	}
+
+	res, err := e.vars.Expand(s)
+	if err != nil {
+		e.err = err
+	}
+	return res
+}
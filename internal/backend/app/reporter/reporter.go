@@ -1,6 +1,57 @@
 package reporter
 
+import (
+	"time"
+
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
 type Reporter interface {
 	Logf(format string, a ...any)
 	Debugf(format string, a ...any)
+
+	// BuildStarted is called before target with its dependencies and vars is built
+	BuildStarted(target string)
+	// BuildFinished is called when build is completed or failed with err
+	BuildFinished(err error)
+	// Started is called when build of target or var is started
+	Started(kind Kind, name string)
+	// Report records result of target or var build
+	Report(r Report)
+	ImagePulled(image string, duration time.Duration)
+	// OutputExported is called when output of target is exported to dest on host
+	OutputExported(target, dest string)
+	// Summary outputs results of reported builds
+	Summary() error
+}
+
+type Kind string
+
+const (
+	TargetKind Kind = "target"
+	VarKind    Kind = "var"
+)
+
+type Status string
+
+const (
+	CachedStatus   Status = "cached"   // All instructions are taken from cache
+	ExecutedStatus Status = "executed" // Some instructions are executed
+	FailedStatus   Status = "failed"
+	SkippedStatus  Status = "skipped" // Build is not started or canceled due to failure of other build
+)
+
+type Report struct {
+	Name     string
+	Kind     Kind
+	Status   Status
+	Duration time.Duration
+	Failure  maybe.Maybe[Failure]
+}
+
+type Failure struct {
+	Error       string
+	Instruction maybe.Maybe[string] // Failed Dockerfile instruction
+	ExitCode    maybe.Maybe[int]    // Exit code of failed command
+	LogTail     []string            // Last lines of failed instruction output
 }
@@ -1,5 +1,6 @@
 package ssh
 
 type AgentProvider interface {
-	Default() string
+	// Default returns path to default ssh agent socket or error when agent is not available
+	Default() (string, error)
 }
@@ -0,0 +1,378 @@
+package buildkit
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	"github.com/moby/buildkit/util/entitlements"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/progress"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+	"github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+const (
+	dockerfileFrontend = "dockerfile.v0"
+	dockerfileName     = "Dockerfile"
+
+	contextLocalDir    = "context"
+	dockerfileLocalDir = "dockerfile"
+)
+
+// NewClient returns docker.Client that solves builds directly on buildkitd via gRPC API, so docker CLI is not required
+func NewClient(address string, tracerProvider trace.TracerProvider, log logger.Logger) docker.Client {
+	return &buildkitClient{
+		address:        address,
+		tracerProvider: tracerProvider,
+		logger:         log,
+	}
+}
+
+type buildkitClient struct {
+	address        string
+	tracerProvider trace.TracerProvider // Propagates trace of brewkit to buildkitd
+	logger         logger.Logger
+}
+
+func (c *buildkitClient) Build(ctx context.Context, d dockerfile.Dockerfile, params docker.BuildParams) (docker.BuildResult, error) {
+	var exports []client.ExportEntry
+	if maybe.Valid(params.Output) {
+		exports = append(exports, exportEntry(maybe.Just(params.Output)))
+	}
+
+	return c.displayedSolve(ctx, d, solveParams{
+		target:       params.Target,
+		sshAgents:    params.SSHAgents,
+		secrets:      params.Secrets,
+		useCache:     true,
+		exports:      exports,
+		remoteCache:  params.RemoteCache,
+		platforms:    params.Platforms,
+		entitlements: params.Entitlements,
+	})
+}
+
+func exportEntry(output docker.Output) client.ExportEntry {
+	switch output.Type {
+	case docker.TarOutput, docker.OCIOutput:
+		exporter := client.ExporterTar
+		if output.Type == docker.OCIOutput {
+			exporter = client.ExporterOCI
+		}
+		return client.ExportEntry{
+			Type: exporter,
+			Output: func(map[string]string) (io.WriteCloser, error) {
+				err := os.MkdirAll(path.Dir(output.Dest), 0o755)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to create dir for %s", output.Dest)
+				}
+				f, err := os.Create(output.Dest)
+				return f, errors.Wrapf(err, "failed to create %s", output.Dest)
+			},
+		}
+	default:
+		return client.ExportEntry{
+			Type:      client.ExporterLocal,
+			OutputDir: output.Dest,
+		}
+	}
+}
+
+func (c *buildkitClient) Value(ctx context.Context, d dockerfile.Dockerfile, params docker.ValueParams) ([]byte, error) {
+	recorder := progress.NewRecorder()
+	output := &strings.Builder{}
+
+	err := c.solve(ctx, d, solveParams{
+		target:       params.Var,
+		sshAgents:    params.SSHAgents,
+		secrets:      params.Secrets,
+		useCache:     params.UseCache,
+		remoteCache:  params.RemoteCache,
+		entitlements: params.Entitlements,
+	}, func(ch chan *client.SolveStatus) error {
+		recorded := make(chan *client.SolveStatus)
+		go recorder.Tee(ch, recorded)
+
+		// Render plain progress to report it on failure
+		_, err := progressui.DisplaySolveStatus(context.TODO(), nil, output, recorded)
+		return err
+	})
+	if err != nil {
+		if entitlementErr := docker.CheckEntitlements(err.Error(), params.Entitlements); entitlementErr != nil {
+			return nil, entitlementErr
+		}
+		if reqErr := progress.NewRequestError(recorder, params.Var, output.String(), 0); reqErr.Instruction != "" {
+			return nil, reqErr
+		}
+		// Solve failed before instruction is executed, e.g. buildkitd is unavailable or registry refused auth
+		return nil, errors.Wrapf(err, "failed to calculate %s var", params.Var)
+	}
+
+	return recorder.RunOutput(params.Var)
+}
+
+func (c *buildkitClient) ListImages(_ context.Context, images []string) ([]docker.Image, error) {
+	// buildkitd resolves and pulls images by itself while solving, so all images considered as existing
+	res := make([]docker.Image, 0, len(images))
+	for _, image := range images {
+		res = append(res, docker.Image{Img: image})
+	}
+	return res, nil
+}
+
+func (c *buildkitClient) PullImage(ctx context.Context, img string) error {
+	def, err := llb.Image(img, llb.ResolveModeForcePull).Marshal(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal llb for %s image", img)
+	}
+
+	return c.withClient(ctx, func(bkClient *client.Client) error {
+		ch := make(chan *client.SolveStatus)
+		eg, egCtx := errgroup.WithContext(ctx)
+		eg.Go(func() error {
+			_, err2 := bkClient.Solve(egCtx, def, client.SolveOpt{}, ch)
+			return errors.Wrapf(err2, "failed to pull %s image", img)
+		})
+		eg.Go(func() error {
+			return progress.Display(ch)
+		})
+		return eg.Wait()
+	})
+}
+
+func (c *buildkitClient) ClearCache(ctx context.Context, params docker.ClearCacheParams) error {
+	var opts []client.PruneOption
+	if params.All {
+		opts = append(opts, client.PruneAll) // Delete all cache
+	}
+
+	return c.withClient(ctx, func(bkClient *client.Client) error {
+		ch := make(chan client.UsageInfo)
+		eg, egCtx := errgroup.WithContext(ctx)
+		eg.Go(func() error {
+			defer close(ch)
+			return errors.Wrap(bkClient.Prune(egCtx, ch, opts...), "failed to prune buildkit cache")
+		})
+		eg.Go(func() error {
+			for usage := range ch {
+				c.logger.Debugf("pruned %s: %d bytes\n", usage.ID, usage.Size)
+			}
+			return nil
+		})
+		return eg.Wait()
+	})
+}
+
+func (c *buildkitClient) BuildImage(ctx context.Context, d dockerfile.Dockerfile, params docker.BuildImageParams) (docker.BuildResult, error) {
+	if params.Load {
+		return docker.BuildResult{}, errors.New("loading image into docker daemon is not supported by buildkit backend, use push instead")
+	}
+
+	attrs := map[string]string{}
+	if len(params.Tags) > 0 {
+		attrs["name"] = strings.Join(params.Tags, ",")
+	}
+	if params.Push {
+		attrs["push"] = "true"
+	}
+
+	return c.displayedSolve(ctx, d, solveParams{
+		target:    params.Target,
+		sshAgents: params.SSHAgents,
+		secrets:   params.Secrets,
+		useCache:  true,
+		exports: []client.ExportEntry{
+			{
+				Type:  client.ExporterImage,
+				Attrs: attrs,
+			},
+		},
+		remoteCache:  params.RemoteCache,
+		labels:       params.Labels,
+		platforms:    params.Platforms,
+		entitlements: params.Entitlements,
+	})
+}
+
+type solveParams struct {
+	target       string
+	sshAgents    []docker.SSHAgent
+	secrets      []docker.SecretData
+	useCache     bool
+	exports      []client.ExportEntry
+	remoteCache  []docker.RemoteCache
+	labels       map[string]string // Labels of resulting image
+	platforms    []string
+	entitlements []docker.Entitlement
+}
+
+// displayedSolve displays solve progress and reports failed instruction with its log when solve fails
+func (c *buildkitClient) displayedSolve(ctx context.Context, d dockerfile.Dockerfile, params solveParams) (docker.BuildResult, error) {
+	recorder := progress.NewRecorder()
+	err := c.solve(ctx, d, params, func(ch chan *client.SolveStatus) error {
+		recorded := make(chan *client.SolveStatus)
+		go recorder.Tee(ch, recorded)
+		return progress.Display(recorded)
+	})
+	if err == nil {
+		return docker.BuildResult{
+			Cached:         recorder.Cached(),
+			ExecutedStages: recorder.ExecutedStages(),
+		}, nil
+	}
+
+	var entitlementErr docker.EntitlementRefusedError
+	if errors.As(err, &entitlementErr) {
+		return docker.BuildResult{}, err
+	}
+
+	if reqErr := progress.NewRequestError(recorder, params.target, "", 0); reqErr.Instruction != "" {
+		return docker.BuildResult{}, reqErr
+	}
+	return docker.BuildResult{}, err
+}
+
+// statusConsumer should read status channel until it closed by solve
+type statusConsumer func(ch chan *client.SolveStatus) error
+
+func (c *buildkitClient) solve(
+	ctx context.Context,
+	d dockerfile.Dockerfile,
+	params solveParams,
+	consumer statusConsumer,
+) error {
+	dockerfileDir, err := os.MkdirTemp("", "brewkit-dockerfile-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create dir for dockerfile")
+	}
+	defer os.RemoveAll(dockerfileDir)
+
+	err = os.WriteFile(path.Join(dockerfileDir, dockerfileName), []byte(d.Format()), 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write dockerfile")
+	}
+
+	attachables, err := c.attachables(params)
+	if err != nil {
+		return err
+	}
+
+	frontendAttrs := map[string]string{
+		"filename": dockerfileName,
+		"target":   params.target,
+	}
+	if !params.useCache {
+		// Disable cache only for target stage, so stages which target copies from are taken from cache
+		frontendAttrs["no-cache"] = params.target
+	}
+	for k, v := range params.labels {
+		frontendAttrs["label:"+k] = v
+	}
+	if len(params.platforms) > 0 {
+		frontendAttrs["platform"] = strings.Join(params.platforms, ",")
+	}
+
+	var cacheImports, cacheExports []client.CacheOptionsEntry
+	for _, rc := range params.remoteCache {
+		if entry, ok := rc.Import(); ok {
+			cacheImports = append(cacheImports, client.CacheOptionsEntry(entry))
+		}
+		if entry, ok := rc.Export(); ok {
+			cacheExports = append(cacheExports, client.CacheOptionsEntry(entry))
+		}
+	}
+
+	solveOpt := client.SolveOpt{
+		Exports:      params.exports,
+		CacheImports: cacheImports,
+		CacheExports: cacheExports,
+		LocalDirs: map[string]string{
+			contextLocalDir:    ".", // Use PWD as context
+			dockerfileLocalDir: dockerfileDir,
+		},
+		Frontend:      dockerfileFrontend,
+		FrontendAttrs: frontendAttrs,
+		Session:       attachables,
+		AllowedEntitlements: slices.Map(params.entitlements, func(e docker.Entitlement) entitlements.Entitlement {
+			return entitlements.Entitlement(e)
+		}),
+	}
+
+	return c.withClient(ctx, func(bkClient *client.Client) error {
+		ch := make(chan *client.SolveStatus)
+		eg, egCtx := errgroup.WithContext(ctx)
+		eg.Go(func() error {
+			_, err2 := bkClient.Solve(egCtx, nil, solveOpt, ch)
+			if err2 != nil {
+				if entitlementErr := docker.CheckEntitlements(err2.Error(), params.entitlements); entitlementErr != nil {
+					return entitlementErr
+				}
+			}
+			return errors.Wrapf(err2, "failed to solve %s target", params.target)
+		})
+		eg.Go(func() error {
+			return consumer(ch)
+		})
+		return eg.Wait()
+	})
+}
+
+func (c *buildkitClient) attachables(params solveParams) ([]session.Attachable, error) {
+	var attachables []session.Attachable
+
+	if len(params.sshAgents) > 0 {
+		sshProvider, err := sshprovider.NewSSHAgentProvider(slices.Map(params.sshAgents, func(a docker.SSHAgent) sshprovider.AgentConfig {
+			return sshprovider.AgentConfig{
+				ID:    a.ID,
+				Paths: []string{a.Path},
+			}
+		}))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create ssh agent provider")
+		}
+		attachables = append(attachables, sshProvider)
+	}
+
+	if len(params.secrets) > 0 {
+		sources := make([]secretsprovider.Source, 0, len(params.secrets))
+		for _, secret := range params.secrets {
+			sources = append(sources, secretsprovider.Source{
+				ID:       secret.ID,
+				FilePath: secret.Path,
+			})
+		}
+
+		store, err := secretsprovider.NewStore(sources)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create secrets store")
+		}
+		attachables = append(attachables, secretsprovider.NewSecretProvider(store))
+	}
+
+	return attachables, nil
+}
+
+func (c *buildkitClient) withClient(ctx context.Context, f func(bkClient *client.Client) error) error {
+	bkClient, err := client.New(ctx, c.address, client.WithTracerProvider(c.tracerProvider))
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to buildkitd on %s", c.address)
+	}
+	defer bkClient.Close()
+
+	return f(bkClient)
+}
@@ -0,0 +1,193 @@
+package buildkit
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
+	"github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+func TestValueReturnsOutputOfRun(t *testing.T) {
+	completed := time.Now()
+	run := digest.FromString("run")
+	address := startFakeBuildkitd(t, &fakeControl{
+		statuses: []*controlapi.StatusResponse{
+			{Vertexes: []*controlapi.Vertex{{Digest: run, Name: "[gitcommit 2/2] RUN git rev-parse HEAD", Started: &completed}}},
+			{Logs: []*controlapi.VertexLog{{Vertex: run, Stream: 1, Msg: []byte("5f2c1a9\n"), Timestamp: completed}}},
+			{Vertexes: []*controlapi.Vertex{{Digest: run, Name: "[gitcommit 2/2] RUN git rev-parse HEAD", Started: &completed, Completed: &completed}}},
+		},
+	})
+
+	value, err := newTestClient(address).Value(context.Background(), dockerfile.Dockerfile{}, docker.ValueParams{Var: "gitcommit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "5f2c1a9" {
+		t.Errorf("expected value %q, got %q", "5f2c1a9", value)
+	}
+}
+
+func TestValueReportsFailedInstruction(t *testing.T) {
+	completed := time.Now()
+	run := digest.FromString("run")
+	address := startFakeBuildkitd(t, &fakeControl{
+		statuses: []*controlapi.StatusResponse{
+			{Vertexes: []*controlapi.Vertex{{Digest: run, Name: "[gitcommit 2/2] RUN git rev-parse HEAD", Started: &completed}}},
+			{Logs: []*controlapi.VertexLog{{Vertex: run, Stream: 2, Msg: []byte("fatal: not a git repository\n"), Timestamp: completed}}},
+			{Vertexes: []*controlapi.Vertex{{
+				Digest:    run,
+				Name:      "[gitcommit 2/2] RUN git rev-parse HEAD",
+				Started:   &completed,
+				Completed: &completed,
+				Error:     `process "/bin/sh -c git rev-parse HEAD" did not complete successfully: exit code: 128`,
+			}}},
+		},
+		solveErr: status.Error(codes.Unknown, "process did not complete successfully: exit code: 128"),
+	})
+
+	_, err := newTestClient(address).Value(context.Background(), dockerfile.Dockerfile{}, docker.ValueParams{Var: "gitcommit"})
+
+	var requestErr *docker.RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("expected RequestError, got %v", err)
+	}
+	if requestErr.Stage != "gitcommit" {
+		t.Errorf("unexpected stage %q", requestErr.Stage)
+	}
+	if strings.Join(requestErr.LogTail, "\n") != "fatal: not a git repository" {
+		t.Errorf("unexpected log tail %q", requestErr.LogTail)
+	}
+}
+
+func TestValueKeepsSolveError(t *testing.T) {
+	address := startFakeBuildkitd(t, &fakeControl{
+		solveErr: status.Error(codes.Unauthenticated, "pull access denied"),
+	})
+
+	_, err := newTestClient(address).Value(context.Background(), dockerfile.Dockerfile{}, docker.ValueParams{Var: "gitcommit"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "pull access denied") {
+		t.Errorf("expected solve error in %q", err)
+	}
+}
+
+func TestValueDisablesCacheOnlyForVarStage(t *testing.T) {
+	for _, useCache := range []bool{true, false} {
+		control := &fakeControl{}
+		address := startFakeBuildkitd(t, control)
+
+		_, err := newTestClient(address).Value(context.Background(), dockerfile.Dockerfile{}, docker.ValueParams{Var: "gitcommit", UseCache: useCache})
+		if err == nil {
+			t.Fatal("expected error of missing var output")
+		}
+
+		attrs := control.frontendAttrs()
+		noCache, ok := attrs["no-cache"]
+		if ok == useCache {
+			t.Errorf("use cache %t: unexpected no-cache attr in %v", useCache, attrs)
+		}
+		if ok && noCache != "gitcommit" {
+			t.Errorf("expected cache disabled only for gitcommit stage, got %q", noCache)
+		}
+	}
+}
+
+func newTestClient(address string) docker.Client {
+	return NewClient(address, trace.NewNoopTracerProvider(), logger.NewLogger(io.Discard, io.Discard, false))
+}
+
+// startFakeBuildkitd serves control API on unix socket and returns its address
+func startFakeBuildkitd(t *testing.T, control *fakeControl) string {
+	t.Helper()
+
+	// Unix socket path is limited, so temp dir with short path is used
+	dir, err := os.MkdirTemp("", "bk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	socket := filepath.Join(dir, "buildkitd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	controlapi.RegisterControlServer(server, control)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return "unix://" + socket
+}
+
+// fakeControl sends statuses of solve, records frontend attrs of solve and fails solve with solveErr
+type fakeControl struct {
+	controlapi.UnimplementedControlServer
+
+	statuses []*controlapi.StatusResponse
+	solveErr error
+
+	mu    sync.Mutex
+	attrs map[string]string
+}
+
+func (c *fakeControl) Solve(_ context.Context, req *controlapi.SolveRequest) (*controlapi.SolveResponse, error) {
+	c.mu.Lock()
+	c.attrs = req.FrontendAttrs
+	c.mu.Unlock()
+
+	if c.solveErr != nil {
+		return nil, c.solveErr
+	}
+	return &controlapi.SolveResponse{}, nil
+}
+
+func (c *fakeControl) Status(_ *controlapi.StatusRequest, stream controlapi.Control_StatusServer) error {
+	for _, s := range c.statuses {
+		err := stream.Send(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Session is held open until client closes it, files of session are not requested
+func (c *fakeControl) Session(stream controlapi.Control_SessionServer) error {
+	for {
+		_, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func (c *fakeControl) frontendAttrs() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attrs
+}
@@ -8,10 +8,14 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/progress"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/tracing"
 	"github.com/ispringtech/brewkit/internal/common/infrastructure/executor"
 	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
@@ -43,6 +47,7 @@ func NewClient(clientConfigPath maybe.Maybe[string], log logger.Logger) (docker.
 		clientConfigPath: clientConfigPath,
 		dockerExecutor:   d,
 		outputParser:     outputParser{},
+		log:              log,
 	}, nil
 }
 
@@ -50,17 +55,18 @@ type client struct {
 	clientConfigPath maybe.Maybe[string]
 	dockerExecutor   executor.Executor
 	outputParser     outputParser
+	log              logger.Logger
 }
 
-func (c *client) Build(ctx context.Context, d dockerfile.Dockerfile, params docker.BuildParams) error {
+func (c *client) Build(ctx context.Context, d dockerfile.Dockerfile, params docker.BuildParams) (docker.BuildResult, error) {
 	var args executor.Args
 
 	c.populateWithCommonArgs(&args)
 	c.populateWithBuilderArgs(&args)
 	args.AddArgs("build")
 
-	if maybe.Valid(params.SSHAgent) {
-		args.AddKV("--ssh", fmt.Sprintf("default=%s", maybe.Just(params.SSHAgent)))
+	for _, agent := range params.SSHAgents {
+		args.AddKV("--ssh", agent.String())
 	}
 
 	if len(params.Secrets) > 0 {
@@ -71,26 +77,19 @@ func (c *client) Build(ctx context.Context, d dockerfile.Dockerfile, params dock
 
 	args.AddKV("--target", params.Target)
 
-	if maybe.Valid(params.Output) {
-		args.AddKV("--output", maybe.Just(params.Output))
+	if len(params.Platforms) > 0 {
+		args.AddKV("--platform", strings.Join(params.Platforms, ","))
 	}
 
-	args.AddArgs("-f-", ".") // Read Dockerfile from stdin and use PWD as context
+	c.populateWithRemoteCacheArgs(&args, params.RemoteCache)
 
-	dockerfileReader := bytes.NewBufferString(d.Format())
-
-	err := c.dockerExecutor.Run(ctx, args, executor.RunParams{
-		Stdin: maybe.NewJust[io.Reader](dockerfileReader),
-	})
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return docker.RequestError{
-				Output: string(exitErr.Stderr),
-				Code:   exitErr.ExitCode(),
-			}
-		}
+	if maybe.Valid(params.Output) {
+		args.AddKV("--output", maybe.Just(params.Output).String())
 	}
-	return nil
+
+	c.populateWithEntitlementsArgs(&args, params.Entitlements)
+
+	return c.runBuild(ctx, d, args, params.Target, params.Entitlements)
 }
 
 func (c *client) Value(ctx context.Context, d dockerfile.Dockerfile, params docker.ValueParams) ([]byte, error) {
@@ -99,37 +98,55 @@ func (c *client) Value(ctx context.Context, d dockerfile.Dockerfile, params dock
 	c.populateWithCommonArgs(&args)
 	c.populateWithBuilderArgs(&args)
 	args.AddArgs("build")
-	args.AddKV("--progress", "plain") // Set to plain to be able to parse output
+	args.AddKV("--progress", "rawjson") // Set to rawjson to decode solve status updates
 
 	if !params.UseCache {
-		args.AddArgs("--no-cache") // Disable cache for target
+		// Disable cache only for var stage, so targets which var copies from are taken from cache
+		args.AddKV("--no-cache-filter", params.Var)
+	}
+
+	for _, agent := range params.SSHAgents {
+		args.AddKV("--ssh", agent.String())
 	}
 
-	if maybe.Valid(params.SSHAgent) {
-		args.AddKV("--ssh", fmt.Sprintf("default=%s", maybe.Just(params.SSHAgent)))
+	for _, secret := range params.Secrets {
+		args.AddKV("--secret", fmt.Sprintf("id=%s,src=%s", secret.ID, secret.Path))
 	}
 
 	args.AddKV("--target", params.Var)
 
+	c.populateWithRemoteCacheArgs(&args, params.RemoteCache)
+	c.populateWithEntitlementsArgs(&args, params.Entitlements)
+
 	args.AddArgs("-f-", ".") // Read Dockerfile from stdin and use PWD as context
 
 	dockerfileReader := bytes.NewBufferString(d.Format())
 
 	output := &bytes.Buffer{}
-	err := c.dockerExecutor.Run(ctx, args, executor.RunParams{
+	runErr := c.dockerExecutor.Run(ctx, args, executor.RunParams{
 		Stdin:  maybe.NewJust[io.Reader](dockerfileReader),
 		Stderr: maybe.NewJust[io.Writer](output),
+		Env:    tracing.Environ(ctx),
 	})
+
+	recorder := progress.NewRecorder()
+	plainOutput, err := c.outputParser.parseRawJSONOutput(output, recorder)
 	if err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			return nil, &docker.RequestError{
-				Output: output.String(),
-			}
-		}
 		return nil, err
 	}
 
-	return c.outputParser.parseBuildOutputForRunTarget(output)
+	if runErr != nil {
+		if entitlementErr := docker.CheckEntitlements(plainOutput, params.Entitlements); entitlementErr != nil {
+			return nil, entitlementErr
+		}
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			return nil, progress.NewRequestError(recorder, params.Var, plainOutput, exitErr.ExitCode())
+		}
+		return nil, errors.Wrapf(runErr, "failed to calculate %s var", params.Var)
+	}
+
+	return recorder.RunOutput(params.Var)
 }
 
 func (c *client) ListImages(ctx context.Context, images []string) ([]docker.Image, error) {
@@ -168,7 +185,11 @@ func (c *client) PullImage(ctx context.Context, img string) error {
 	args.AddArgs("pull")
 	args.AddArgs(img)
 
-	return c.dockerExecutor.Run(ctx, args, executor.RunParams{})
+	// Pull progress is diagnostic output like build progress, so stdout is left for brewkit output
+	return c.dockerExecutor.Run(ctx, args, executor.RunParams{
+		Stdout: maybe.NewJust[io.Writer](os.Stderr),
+		Env:    tracing.Environ(ctx),
+	})
 }
 
 func (c *client) ClearCache(ctx context.Context, params docker.ClearCacheParams) error {
@@ -185,9 +206,111 @@ func (c *client) ClearCache(ctx context.Context, params docker.ClearCacheParams)
 	return c.dockerExecutor.Run(ctx, args, executor.RunParams{})
 }
 
-func (c *client) BuildImage(_ context.Context, _ string) error {
-	// TODO implement me
-	panic("implement me")
+func (c *client) BuildImage(ctx context.Context, d dockerfile.Dockerfile, params docker.BuildImageParams) (docker.BuildResult, error) {
+	var args executor.Args
+
+	c.populateWithCommonArgs(&args)
+	c.populateWithBuilderArgs(&args)
+	args.AddArgs("build")
+
+	for _, agent := range params.SSHAgents {
+		args.AddKV("--ssh", agent.String())
+	}
+
+	for _, secret := range params.Secrets {
+		args.AddKV("--secret", fmt.Sprintf("id=%s,src=%s", secret.ID, secret.Path))
+	}
+
+	args.AddKV("--target", params.Target)
+
+	if len(params.Platforms) > 0 {
+		args.AddKV("--platform", strings.Join(params.Platforms, ","))
+	}
+
+	c.populateWithRemoteCacheArgs(&args, params.RemoteCache)
+
+	for _, tag := range params.Tags {
+		args.AddKV("--tag", tag)
+	}
+
+	labels := make([]string, 0, len(params.Labels))
+	for k, v := range params.Labels {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		args.AddKV("--label", label)
+	}
+
+	if params.Push {
+		args.AddArgs("--push")
+	}
+
+	if params.Load {
+		args.AddArgs("--load")
+	}
+
+	c.populateWithEntitlementsArgs(&args, params.Entitlements)
+
+	return c.runBuild(ctx, d, args, params.Target, params.Entitlements)
+}
+
+// runBuild executes build with progress decoded from output, so failed instruction and its log can be reported
+func (c *client) runBuild(
+	ctx context.Context,
+	d dockerfile.Dockerfile,
+	args executor.Args,
+	target string,
+	entitlements []docker.Entitlement,
+) (docker.BuildResult, error) {
+	args.AddKV("--progress", "rawjson") // Set to rawjson to decode solve status updates
+	args.AddArgs("-f-", ".")            // Read Dockerfile from stdin and use PWD as context
+
+	recorder := progress.NewRecorder()
+	outputReader, outputWriter := io.Pipe()
+
+	type displayResult struct {
+		otherLines []string
+		err        error
+	}
+	displayed := make(chan displayResult, 1)
+	go func() {
+		otherLines, err := c.outputParser.displayRawJSONOutput(outputReader, recorder, progress.Display)
+		// Drain rest of output to not block docker client when output can not be decoded
+		_, _ = io.Copy(io.Discard, outputReader)
+		displayed <- displayResult{otherLines: otherLines, err: err}
+	}()
+
+	runErr := c.dockerExecutor.Run(ctx, args, executor.RunParams{
+		Stdin:  maybe.NewJust[io.Reader](bytes.NewBufferString(d.Format())),
+		Stderr: maybe.NewJust[io.Writer](outputWriter),
+		Env:    tracing.Environ(ctx),
+	})
+	outputWriter.Close()
+
+	res := <-displayed
+	// Lines that are not status updates are errors of docker client
+	for _, line := range res.otherLines {
+		c.log.Logf("%s\n", line)
+	}
+	output := strings.Join(res.otherLines, "\n")
+
+	if runErr != nil {
+		if entitlementErr := docker.CheckEntitlements(output, entitlements); entitlementErr != nil {
+			return docker.BuildResult{}, entitlementErr
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			return docker.BuildResult{}, progress.NewRequestError(recorder, target, output, exitErr.ExitCode())
+		}
+		return docker.BuildResult{}, errors.Wrapf(runErr, "failed to build %s target", target)
+	}
+
+	return docker.BuildResult{
+		Cached:         recorder.Cached(),
+		ExecutedStages: recorder.ExecutedStages(),
+	}, errors.Wrap(res.err, "failed to display build progress")
 }
 
 func (c *client) populateWithCommonArgs(args *executor.Args) {
@@ -199,3 +322,35 @@ func (c *client) populateWithCommonArgs(args *executor.Args) {
 func (c *client) populateWithBuilderArgs(args *executor.Args) {
 	args.AddArgs("builder") // Use builder explicitly
 }
+
+func (c *client) populateWithRemoteCacheArgs(args *executor.Args, remoteCache []docker.RemoteCache) {
+	for _, rc := range remoteCache {
+		if entry, ok := rc.Import(); ok {
+			args.AddKV("--cache-from", formatCacheEntry(entry))
+		}
+		if entry, ok := rc.Export(); ok {
+			args.AddKV("--cache-to", formatCacheEntry(entry))
+		}
+	}
+}
+
+func (c *client) populateWithEntitlementsArgs(args *executor.Args, entitlements []docker.Entitlement) {
+	for _, e := range entitlements {
+		args.AddKV("--allow", string(e))
+	}
+}
+
+// formatCacheEntry formats cache entry as csv value of --cache-from and --cache-to flags
+func formatCacheEntry(entry docker.CacheEntry) string {
+	keys := make([]string, 0, len(entry.Attrs))
+	for k := range entry.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{fmt.Sprintf("type=%s", entry.Type)}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, entry.Attrs[k]))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/executor"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+func TestBuildReportsFailedInstruction(t *testing.T) {
+	log := &testLogger{}
+	c := &client{
+		dockerExecutor: fixtureExecutor{fixture: "testdata/failed.rawjson", exitCode: 1},
+		outputParser:   outputParser{},
+		log:            log,
+	}
+
+	_, err := c.Build(context.Background(), dockerfile.Dockerfile{}, docker.BuildParams{Target: "gobuild"})
+
+	var requestErr *docker.RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("expected RequestError, got %v", err)
+	}
+	if requestErr.Instruction != "[gobuild 2/2] RUN <<EOF (go build ./...)" {
+		t.Errorf("unexpected instruction %q", requestErr.Instruction)
+	}
+	if requestErr.Stage != "gobuild" {
+		t.Errorf("unexpected stage %q", requestErr.Stage)
+	}
+	if !maybe.Valid(requestErr.ExitCode) || maybe.Just(requestErr.ExitCode) != 1 {
+		t.Errorf("expected exit code 1, got %v", requestErr.ExitCode)
+	}
+
+	expectedLogTail := []string{"# example.com/app", "./main.go:5:2: undefined: foo"}
+	if strings.Join(requestErr.LogTail, "\n") != strings.Join(expectedLogTail, "\n") {
+		t.Errorf("expected log tail %q, got %q", expectedLogTail, requestErr.LogTail)
+	}
+
+	// Lines that are not status updates are logged
+	if !strings.Contains(log.String(), "ERROR: failed to solve") {
+		t.Errorf("expected error of docker client in log, got %q", log.String())
+	}
+}
+
+func TestValueDisablesCacheOnlyForVarStage(t *testing.T) {
+	for _, useCache := range []bool{true, false} {
+		var args executor.Args
+		c := &client{
+			dockerExecutor: fixtureExecutor{fixture: "testdata/var.rawjson", args: &args},
+			outputParser:   outputParser{},
+			log:            &testLogger{},
+		}
+
+		value, err := c.Value(context.Background(), dockerfile.Dockerfile{}, docker.ValueParams{Var: "gitcommit", UseCache: useCache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "5f2c1a9" {
+			t.Errorf("expected value %q, got %q", "5f2c1a9", value)
+		}
+
+		joined := strings.Join(args, " ")
+		if strings.Contains(joined, "--no-cache ") {
+			t.Errorf("expected cache not disabled for all stages, got args %q", joined)
+		}
+		if hasFilter := strings.Contains(joined, "--no-cache-filter gitcommit"); hasFilter == useCache {
+			t.Errorf("use cache %t: unexpected no-cache filter in args %q", useCache, joined)
+		}
+	}
+}
+
+// fixtureExecutor writes fixture to stderr as docker client does with --progress rawjson and exits with exitCode
+type fixtureExecutor struct {
+	fixture  string
+	exitCode int
+	args     *executor.Args // Records args of run when set
+}
+
+func (e fixtureExecutor) Run(_ context.Context, args executor.Args, params executor.RunParams) error {
+	if e.args != nil {
+		*e.args = args
+	}
+
+	data, err := os.ReadFile(e.fixture)
+	if err != nil {
+		return err
+	}
+
+	_, err = maybe.Just(params.Stderr).Write(data)
+	if err != nil {
+		return err
+	}
+
+	if e.exitCode == 0 {
+		return nil
+	}
+	// ExitError can be only obtained from exited process
+	return exec.Command("sh", "-c", fmt.Sprintf("exit %d", e.exitCode)).Run()
+}
+
+type testLogger struct {
+	strings.Builder
+}
+
+func (l *testLogger) Logf(format string, a ...any) {
+	_, _ = fmt.Fprintf(&l.Builder, format, a...)
+}
+
+func (l *testLogger) Outputf(format string, a ...any) {
+	_, _ = fmt.Fprintf(&l.Builder, format, a...)
+}
+
+func (l *testLogger) Debugf(format string, a ...any) {
+	_, _ = fmt.Fprintf(&l.Builder, format, a...)
+}
@@ -2,105 +2,94 @@ package docker
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"io"
-	"regexp"
-	"strconv"
 	"strings"
 
+	bkclient "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/progress/progressui"
 	"github.com/pkg/errors"
-)
-
-const (
-	doneSymbol = "DONE"
-)
 
-var (
-	outputHeader = regexp.MustCompile(`#\d+ \[.*(?P<progress>\d/\d)] (?P<command>[A-Z]+) .*`)
-	outputLine   = regexp.MustCompile(`#\d+ (?P<mark>\S+) (?P<output>.*)$`)
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/progress"
 )
 
 type outputParser struct{}
 
-func (p outputParser) parseBuildOutputForRunTarget(output io.Reader) ([]byte, error) {
-	scanner := bufio.NewScanner(output)
-	for scanner.Scan() {
-		submatch := outputHeader.FindStringSubmatch(scanner.Text())
-		if len(submatch) != len(outputHeader.SubexpNames()) {
-			// It is not a header since fully do not match regexp
-			continue
-		}
-
-		instruction := submatch[2]
-		if instruction != "RUN" { // Check only RUN instructions for output
-			continue
-		}
-
-		progress := submatch[1]
-		completed, err := completed(progress)
-		if err != nil {
-			return nil, err
-		}
-
-		if !completed { // Skip uncompleted stages
-			continue
-		}
-
-		ok := scanner.Scan() // Skip line with intermediate container hash
-		if !ok {
-			return nil, errors.New("failed to skip line with intermediate container hash")
-		}
-
-		commandOutput, err := scanCommandOutput(scanner)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to scan command output")
-		}
+// parseRawJSONOutput decodes solve status updates from output of build with '--progress rawjson' into recorder.
+// Returns progress rendered in plain format with lines that are not status updates, e.g. errors of docker client
+func (p outputParser) parseRawJSONOutput(output io.Reader, recorder *progress.Recorder) (string, error) {
+	plainOutput := &strings.Builder{}
+	otherLines, err := p.displayRawJSONOutput(output, recorder, func(ch chan *bkclient.SolveStatus) error {
+		_, err := progressui.DisplaySolveStatus(context.TODO(), nil, plainOutput, ch)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
 
-		return []byte(commandOutput), nil
+	for _, line := range otherLines {
+		plainOutput.WriteString(line)
+		plainOutput.WriteString("\n")
 	}
 
-	return nil, errors.New("command output is not fully parsed")
+	return plainOutput.String(), nil
 }
 
-func scanCommandOutput(scanner *bufio.Scanner) (string, error) {
-	var res []string
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		submatch := outputLine.FindStringSubmatch(line)
-		if len(submatch) != len(outputLine.SubexpNames()) {
-			return "", errors.Errorf("invalid output line format: %s", line)
-		}
-
-		mark := submatch[1]
-		output := submatch[2]
-
-		if mark == doneSymbol {
-			return strings.Join(res, "\n"), nil
-		}
-
-		res = append(res, output)
+// displayRawJSONOutput decodes solve status updates from output into recorder and passes them to display while output is read.
+// Returns lines that are not status updates
+func (p outputParser) displayRawJSONOutput(
+	output io.Reader,
+	recorder *progress.Recorder,
+	display func(ch chan *bkclient.SolveStatus) error,
+) ([]string, error) {
+	statuses := make(chan *bkclient.SolveStatus)
+	recorded := make(chan *bkclient.SolveStatus)
+	go recorder.Tee(statuses, recorded)
+
+	displayErr := make(chan error, 1)
+	go func() {
+		// Display not bound to context since it should read channel until it closed
+		displayErr <- display(recorded)
+	}()
+
+	otherLines, err := p.decodeStatuses(output, statuses)
+	close(statuses)
+
+	// Wait for display to render all statuses
+	if err2 := <-displayErr; err == nil && err2 != nil {
+		err = errors.Wrap(err2, "failed to render progress")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	output := strings.Join(res, "\n")
-	return "", errors.Errorf("output line is not terminated by %s: current line: %s", doneSymbol, output)
+	return otherLines, nil
 }
 
-func completed(progress string) (bool, error) {
-	const progressSeparator = "/"
-	parts := strings.Split(progress, progressSeparator)
-	if len(parts) != 2 {
-		return false, errors.Errorf("incorrect progress format %s", progress)
-	}
+func (p outputParser) decodeStatuses(output io.Reader, statuses chan<- *bkclient.SolveStatus) (otherLines []string, err error) {
+	// Read by lines without limit on line length since logs may be huge
+	reader := bufio.NewReader(output)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, errors.Wrap(readErr, "failed to read build output")
+		}
 
-	readyPart, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return false, errors.Errorf("incorrect progress format %s", progress)
-	}
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 {
+			// Each line of rawjson progress is client.SolveStatus encoded by docker client
+			var status bkclient.SolveStatus
+			if line[0] == '{' && json.Unmarshal(line, &status) == nil {
+				statuses <- &status
+			} else {
+				otherLines = append(otherLines, string(line))
+			}
+		}
 
-	allPart, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return false, errors.Errorf("incorrect progress format %s", progress)
+		if readErr == io.EOF {
+			return otherLines, nil
+		}
 	}
-
-	return readyPart == allPart, nil
 }
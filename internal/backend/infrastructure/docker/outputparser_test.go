@@ -0,0 +1,34 @@
+package docker
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/progress"
+)
+
+func TestParseRawJSONOutputRecordsLogs(t *testing.T) {
+	output, err := os.Open("testdata/var.rawjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	recorder := progress.NewRecorder()
+	plainOutput, err := outputParser{}.parseRawJSONOutput(output, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := recorder.RunOutput("gitcommit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "5f2c1a9" {
+		t.Errorf("expected var value %q, got %q", "5f2c1a9", value)
+	}
+
+	if plainOutput == "" {
+		t.Error("expected rendered progress")
+	}
+}
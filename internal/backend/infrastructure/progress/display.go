@@ -0,0 +1,22 @@
+package progress
+
+import (
+	"context"
+	"os"
+
+	"github.com/containerd/console"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/progress/progressui"
+)
+
+// Display renders solve status updates to stderr, interactive progress is used when stderr is terminal
+func Display(ch chan *client.SolveStatus) error {
+	var con console.Console
+	if cf, err := console.ConsoleFromFile(os.Stderr); err == nil {
+		con = cf
+	}
+
+	// Display not bound to context since it should read channel until solve closes it
+	_, err := progressui.DisplaySolveStatus(context.TODO(), con, os.Stderr, ch)
+	return err
+}
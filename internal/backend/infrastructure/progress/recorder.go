@@ -0,0 +1,207 @@
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+const (
+	stdoutStream = 1
+)
+
+// Dockerfile instructions are named as [stage 2/3] RUN go build ./... or [linux/amd64 stage 2/3] RUN go build ./...
+var instructionRegexp = regexp.MustCompile(`^\[(?:[^\]]+ )?([^\] ]+) \d+/\d+\] `)
+
+// buildkit reports failed command as: process "/bin/sh -c ..." did not complete successfully: exit code: 2
+var exitCodeRegexp = regexp.MustCompile(`exit code: (\d+)`)
+
+// Recorder accumulates BuildKit solve status updates to inspect vertexes and their logs after solve
+type Recorder struct {
+	mu       sync.Mutex
+	vertexes map[digest.Digest]*client.Vertex
+	order    []digest.Digest // Vertexes in order of appearance
+	logs     map[digest.Digest]map[int]*bytes.Buffer
+	output   map[digest.Digest]*bytes.Buffer // Interleaved stdout and stderr of vertex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		vertexes: map[digest.Digest]*client.Vertex{},
+		logs:     map[digest.Digest]map[int]*bytes.Buffer{},
+		output:   map[digest.Digest]*bytes.Buffer{},
+	}
+}
+
+func (r *Recorder) Record(s *client.SolveStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range s.Vertexes {
+		if _, ok := r.vertexes[v.Digest]; !ok {
+			r.order = append(r.order, v.Digest)
+		}
+		r.vertexes[v.Digest] = v
+	}
+
+	for _, l := range s.Logs {
+		streams, ok := r.logs[l.Vertex]
+		if !ok {
+			streams = map[int]*bytes.Buffer{}
+			r.logs[l.Vertex] = streams
+		}
+
+		buffer, ok := streams[l.Stream]
+		if !ok {
+			buffer = &bytes.Buffer{}
+			streams[l.Stream] = buffer
+		}
+
+		buffer.Write(l.Data)
+
+		output, ok := r.output[l.Vertex]
+		if !ok {
+			output = &bytes.Buffer{}
+			r.output[l.Vertex] = output
+		}
+
+		output.Write(l.Data)
+	}
+}
+
+// Tee records status updates from src and forwards them to dst. dst is closed when src is closed
+func (r *Recorder) Tee(src <-chan *client.SolveStatus, dst chan<- *client.SolveStatus) {
+	defer close(dst)
+	for s := range src {
+		r.Record(s)
+		dst <- s
+	}
+}
+
+// Drain records status updates from src until it closed
+func (r *Recorder) Drain(src <-chan *client.SolveStatus) {
+	for s := range src {
+		r.Record(s)
+	}
+}
+
+// RunOutput returns stdout of RUN instruction in stage without trailing newline
+func (r *Recorder) RunOutput(stage string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prefix := fmt.Sprintf("[%s ", stage)
+
+	for i := len(r.order) - 1; i >= 0; i-- {
+		v := r.vertexes[r.order[i]]
+		if !strings.HasPrefix(v.Name, prefix) || !strings.Contains(v.Name, "] RUN ") {
+			continue
+		}
+
+		if v.Completed == nil || v.Error != "" {
+			return nil, errors.Errorf("RUN instruction in %s stage is not completed", stage)
+		}
+
+		if v.Cached {
+			return nil, errors.Errorf("RUN instruction in %s stage is cached, so there is no output", stage)
+		}
+
+		var output []byte
+		if buffer, ok := r.logs[v.Digest][stdoutStream]; ok {
+			output = buffer.Bytes()
+		}
+
+		return bytes.TrimSuffix(output, []byte("\n")), nil
+	}
+
+	return nil, errors.Errorf("RUN instruction in %s stage not found", stage)
+}
+
+// Cached reports whether all Dockerfile instructions are taken from cache. FROM is not considered since it only resolves image
+func (r *Recorder) Cached() bool {
+	return len(r.ExecutedStages()) == 0
+}
+
+// ExecutedStages returns names of stages with instructions that are not taken from cache
+func (r *Recorder) ExecutedStages() maps.Set[string] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := maps.Set[string]{}
+	for _, v := range r.vertexes {
+		matches := instructionRegexp.FindStringSubmatch(v.Name)
+		if matches == nil || strings.HasPrefix(v.Name[len(matches[0]):], "FROM ") {
+			continue
+		}
+
+		if !v.Cached {
+			res.Add(matches[1])
+		}
+	}
+
+	return res
+}
+
+// InstructionStage returns name of stage of Dockerfile instruction vertex
+func InstructionStage(vertex string) maybe.Maybe[string] {
+	matches := instructionRegexp.FindStringSubmatch(vertex)
+	if matches == nil {
+		return maybe.NewNone[string]()
+	}
+	return maybe.NewJust(matches[1])
+}
+
+// Failure describes failed vertex of solve
+type Failure struct {
+	Vertex   string           // Name of vertex, e.g. [gobuild 2/3] RUN go build ./...
+	Error    string           // Error reported by buildkit
+	ExitCode maybe.Maybe[int] // Exit code of failed command
+	LogTail  []string         // Last lines of vertex output
+}
+
+// Failure returns last failed vertex with last logLines lines of its output
+func (r *Recorder) Failure(logLines int) maybe.Maybe[Failure] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.order) - 1; i >= 0; i-- {
+		v := r.vertexes[r.order[i]]
+		if v.Error == "" {
+			continue
+		}
+
+		var lines []string
+		if output, ok := r.output[v.Digest]; ok {
+			lines = strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		}
+		if len(lines) > logLines {
+			lines = lines[len(lines)-logLines:]
+		}
+
+		exitCode := maybe.NewNone[int]()
+		if matches := exitCodeRegexp.FindStringSubmatch(v.Error); matches != nil {
+			if code, err := strconv.Atoi(matches[1]); err == nil {
+				exitCode = maybe.NewJust(code)
+			}
+		}
+
+		return maybe.NewJust(Failure{
+			Vertex:   v.Name,
+			Error:    v.Error,
+			ExitCode: exitCode,
+			LogTail:  lines,
+		})
+	}
+
+	return maybe.NewNone[Failure]()
+}
@@ -0,0 +1,32 @@
+package progress
+
+import (
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+const (
+	failureLogLines = 20
+)
+
+// NewRequestError describes failed build of target, failed instruction is taken from recorded progress when it is found
+func NewRequestError(recorder *Recorder, target, output string, code int) *docker.RequestError {
+	res := &docker.RequestError{
+		Target: target,
+		Output: output,
+		Code:   code,
+	}
+
+	failure := recorder.Failure(failureLogLines)
+	if maybe.Valid(failure) {
+		f := maybe.Just(failure)
+		res.Instruction = f.Vertex
+		if stage := InstructionStage(f.Vertex); maybe.Valid(stage) {
+			res.Stage = maybe.Just(stage)
+		}
+		res.ExitCode = f.ExitCode
+		res.LogTail = f.LogTail
+	}
+
+	return res
+}
@@ -0,0 +1,173 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+const (
+	logEvent            = "log"
+	debugEvent          = "debug"
+	buildStartedEvent   = "buildStarted"
+	buildFinishedEvent  = "buildFinished"
+	imagePulledEvent    = "imagePulled"
+	outputExportedEvent = "outputExported"
+	varResolvedEvent    = "varResolved"
+	targetFinishedEvent = "targetFinished"
+	targetCachedEvent   = "targetCached"
+)
+
+// NewEventReporter returns reporter that writes build progress to w as newline-delimited JSON events
+func NewEventReporter(w io.Writer, debug bool, reportPath maybe.Maybe[string]) reporter.Reporter {
+	return &eventReporter{
+		reports: reports{
+			reportPath: reportPath,
+		},
+		encoder: json.NewEncoder(w),
+		debug:   debug,
+	}
+}
+
+type eventReporter struct {
+	reports
+
+	encoderMu  sync.Mutex
+	encoder    *json.Encoder
+	debug      bool
+	buildStart time.Time
+}
+
+type event struct {
+	Time     time.Time    `json:"time"`
+	Event    string       `json:"event"`
+	Name     string       `json:"name,omitempty"` // Target, var or image
+	Message  string       `json:"message,omitempty"`
+	Duration float64      `json:"duration,omitempty"` // Seconds
+	Cached   bool         `json:"cached,omitempty"`
+	Dest     string       `json:"dest,omitempty"`
+	Status   string       `json:"status,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Failure  *jsonFailure `json:"failure,omitempty"`
+}
+
+func (r *eventReporter) Logf(format string, a ...any) {
+	r.emit(event{
+		Event:   logEvent,
+		Message: strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"),
+	})
+}
+
+func (r *eventReporter) Debugf(format string, a ...any) {
+	if !r.debug {
+		return
+	}
+
+	r.emit(event{
+		Event:   debugEvent,
+		Message: strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"),
+	})
+}
+
+func (r *eventReporter) BuildStarted(target string) {
+	r.buildStart = time.Now()
+	r.emit(event{
+		Event: buildStartedEvent,
+		Name:  target,
+	})
+}
+
+func (r *eventReporter) BuildFinished(err error) {
+	e := event{
+		Event:    buildFinishedEvent,
+		Status:   "succeeded",
+		Duration: time.Since(r.buildStart).Seconds(),
+	}
+	if err != nil {
+		e.Status = "failed"
+		e.Error = err.Error()
+	}
+	r.emit(e)
+}
+
+func (r *eventReporter) Started(kind reporter.Kind, name string) {
+	r.emit(event{
+		Event: string(kind) + "Started",
+		Name:  name,
+	})
+}
+
+func (r *eventReporter) Report(report reporter.Report) {
+	r.reports.Report(report)
+
+	e := event{
+		Event:    reportEvent(report),
+		Name:     report.Name,
+		Duration: report.Duration.Seconds(),
+		Cached:   report.Status == reporter.CachedStatus,
+	}
+
+	if maybe.Valid(report.Failure) {
+		failure := mapFailure(maybe.Just(report.Failure))
+		e.Failure = &failure
+	}
+
+	r.emit(e)
+}
+
+func (r *eventReporter) ImagePulled(image string, duration time.Duration) {
+	r.emit(event{
+		Event:    imagePulledEvent,
+		Name:     image,
+		Duration: duration.Seconds(),
+	})
+}
+
+func (r *eventReporter) OutputExported(target, dest string) {
+	r.emit(event{
+		Event: outputExportedEvent,
+		Name:  target,
+		Dest:  dest,
+	})
+}
+
+// Summary only writes JSON report, since results are already emitted as events
+func (r *eventReporter) Summary() error {
+	_, err := r.writeReport()
+	return err
+}
+
+// reportEvent returns name of event for result of build: targetFinished, targetCached, varResolved, varFailed, etc.
+func reportEvent(report reporter.Report) string {
+	switch report.Status {
+	case reporter.FailedStatus:
+		return string(report.Kind) + "Failed"
+	case reporter.SkippedStatus:
+		return string(report.Kind) + "Skipped"
+	}
+
+	switch {
+	case report.Kind == reporter.VarKind:
+		return varResolvedEvent
+	case report.Status == reporter.CachedStatus:
+		return targetCachedEvent
+	default:
+		return targetFinishedEvent
+	}
+}
+
+func (r *eventReporter) emit(e event) {
+	e.Time = time.Now()
+
+	r.encoderMu.Lock()
+	defer r.encoderMu.Unlock()
+
+	// Events are best effort, so broken output does not fail build
+	_ = r.encoder.Encode(e)
+}
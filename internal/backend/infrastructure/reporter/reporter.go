@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+// NewReporter returns reporter that prints summary table of builds to log and writes JSON report to reportPath if it passed
+func NewReporter(log logger.Logger, reportPath maybe.Maybe[string]) reporter.Reporter {
+	return &textReporter{
+		Logger: log,
+		reports: reports{
+			reportPath: reportPath,
+		},
+	}
+}
+
+type textReporter struct {
+	logger.Logger
+	reports
+}
+
+func (r *textReporter) BuildStarted(string) {}
+
+// BuildFinished is not reported, since error is returned to user
+func (r *textReporter) BuildFinished(error) {}
+
+func (r *textReporter) Started(reporter.Kind, string) {}
+
+// ImagePulled is not reported, since docker reports pull progress by itself
+func (r *textReporter) ImagePulled(string, time.Duration) {}
+
+func (r *textReporter) OutputExported(string, string) {}
+
+func (r *textReporter) Summary() error {
+	all, err := r.writeReport()
+	if err != nil {
+		return err
+	}
+
+	if len(all) == 0 {
+		return nil
+	}
+
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	_, _ = w.Write([]byte("NAME\tKIND\tSTATUS\tDURATION\n"))
+	for _, report := range all {
+		duration := "-"
+		if report.Status != reporter.SkippedStatus {
+			duration = report.Duration.Round(100 * time.Millisecond).String()
+		}
+
+		_, _ = w.Write([]byte(strings.Join([]string{
+			report.Name,
+			string(report.Kind),
+			string(report.Status),
+			duration,
+		}, "\t") + "\n"))
+	}
+	err = w.Flush()
+	if err != nil {
+		return errors.Wrap(err, "failed to render summary table")
+	}
+
+	r.Logf("\nSummary:\n%s", b.String())
+	return nil
+}
@@ -0,0 +1,88 @@
+package reporter
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/app/reporter"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+)
+
+// reports collects results of builds and writes them to JSON report
+type reports struct {
+	reportPath maybe.Maybe[string]
+
+	mu      sync.Mutex
+	reports []reporter.Report // Reports in order of completion
+}
+
+func (r *reports) Report(report reporter.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports = append(r.reports, report)
+}
+
+// writeReport writes JSON report if report path passed and returns all collected reports
+func (r *reports) writeReport() ([]reporter.Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all := append([]reporter.Report(nil), r.reports...)
+	if !maybe.Valid(r.reportPath) {
+		return all, nil
+	}
+
+	reportPath := maybe.Just(r.reportPath)
+	report := jsonReport{
+		Builds: slices.Map(all, func(report reporter.Report) jsonBuild {
+			return jsonBuild{
+				Name:     report.Name,
+				Kind:     string(report.Kind),
+				Status:   string(report.Status),
+				Duration: report.Duration.Seconds(),
+				Failure:  maybe.Map(report.Failure, mapFailure),
+			}
+		}),
+	}
+
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal report to json")
+	}
+
+	err = os.WriteFile(reportPath, data, 0o644)
+	return all, errors.Wrapf(err, "failed to write report to %s", reportPath)
+}
+
+func mapFailure(f reporter.Failure) jsonFailure {
+	return jsonFailure{
+		Error:       f.Error,
+		Instruction: f.Instruction,
+		ExitCode:    f.ExitCode,
+		Log:         f.LogTail,
+	}
+}
+
+type jsonReport struct {
+	Builds []jsonBuild `json:"builds"`
+}
+
+type jsonBuild struct {
+	Name     string                   `json:"name"`
+	Kind     string                   `json:"kind"`
+	Status   string                   `json:"status"`
+	Duration float64                  `json:"duration"` // Seconds
+	Failure  maybe.Maybe[jsonFailure] `json:"failure"`
+}
+
+type jsonFailure struct {
+	Error       string              `json:"error"`
+	Instruction maybe.Maybe[string] `json:"instruction"`
+	ExitCode    maybe.Maybe[int]    `json:"exitCode"`
+	Log         []string            `json:"log"`
+}
@@ -12,19 +12,18 @@ const (
 	sshAuthSock = "SSH_AUTH_SOCK"
 )
 
-func NewAgentProvider() (ssh.AgentProvider, error) {
-	socket, found := os.LookupEnv(sshAuthSock)
-	if !found {
-		return nil, errors.Errorf("ssh auth socket via env %s not found", sshAuthSock)
-	}
-
-	return &agentProvider{defaultAgent: socket}, nil
+// NewAgentProvider returns provider of agent from SSH_AUTH_SOCK, absence of agent is reported only when it is requested
+func NewAgentProvider() ssh.AgentProvider {
+	return &agentProvider{}
 }
 
-type agentProvider struct {
-	defaultAgent string
-}
+type agentProvider struct{}
+
+func (provider agentProvider) Default() (string, error) {
+	socket, found := os.LookupEnv(sshAuthSock)
+	if !found || socket == "" {
+		return "", errors.Errorf("ssh auth socket via env %s not found", sshAuthSock)
+	}
 
-func (provider agentProvider) Default() string {
-	return provider.defaultAgent
+	return socket, nil
 }
@@ -0,0 +1,23 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Environ returns env with trace context of ctx for child processes, so docker CLI continues trace of brewkit.
+// Names follow OpenTelemetry env carrier proposal supported by buildx
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	var env []string
+	if parent := carrier.Get("traceparent"); parent != "" {
+		env = append(env, "TRACEPARENT="+parent)
+	}
+	if state := carrier.Get("tracestate"); state != "" {
+		env = append(env, "TRACESTATE="+state)
+	}
+	return env
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEnvironPropagatesTraceContext(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := trace.ParseTraceState("brewkit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: state,
+	}))
+
+	env := Environ(ctx)
+
+	expected := []string{
+		"TRACEPARENT=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"TRACESTATE=brewkit=1",
+	}
+	if len(env) != len(expected) {
+		t.Fatalf("expected env %q, got %q", expected, env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], env[i])
+		}
+	}
+}
+
+func TestEnvironIsEmptyWithoutTrace(t *testing.T) {
+	env := Environ(context.Background())
+	if len(env) != 0 {
+		t.Errorf("expected no env without trace, got %q", env)
+	}
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "brewkit"
+)
+
+var (
+	// endpointEnvs enable tracing without config, exporter reads them itself
+	endpointEnvs = []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	}
+)
+
+// Provider creates tracers and flushes recorded spans on Shutdown
+type Provider interface {
+	trace.TracerProvider
+	Shutdown(ctx context.Context) error
+}
+
+type Params struct {
+	Enabled  bool
+	Endpoint string // host:port of OTLP gRPC collector, OTEL_EXPORTER_OTLP_* env or localhost:4317 are used when empty
+	Insecure bool
+	Version  string // Version of brewkit reported in resource attributes
+}
+
+// EnvConfigured reports whether OTLP exporter is configured by standard OpenTelemetry env
+func EnvConfigured() bool {
+	for _, env := range endpointEnvs {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProvider returns provider which exports spans via OTLP gRPC, when tracing is disabled spans are not recorded
+func NewProvider(ctx context.Context, params Params) (Provider, error) {
+	if !params.Enabled {
+		return noopProvider{TracerProvider: trace.NewNoopTracerProvider()}, nil
+	}
+
+	var opts []otlptracegrpc.Option
+	if params.Endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(params.Endpoint))
+	}
+	if params.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create OTLP trace exporter")
+	}
+
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(params.Version),
+		),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override defaults
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create trace resource")
+	}
+
+	// Trace context is propagated to buildkitd via gRPC metadata
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+type noopProvider struct {
+	trace.TracerProvider
+}
+
+func (noopProvider) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/backend/app/build"
+	"github.com/ispringtech/brewkit/internal/backend/app/docker"
+	"github.com/ispringtech/brewkit/internal/backend/infrastructure/reporter"
+	"github.com/ispringtech/brewkit/internal/common/infrastructure/logger"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/dockerfile"
+)
+
+func TestProviderExportsBuildSpans(t *testing.T) {
+	collector := startCollector(t)
+
+	ctx := context.Background()
+	provider, err := NewProvider(ctx, Params{
+		Enabled:  true,
+		Endpoint: collector.address,
+		Insecure: true,
+		Version:  "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := build.NewBuildService(
+		cachedDockerClient{},
+		"dockerfile-image",
+		nil,
+		reporter.NewReporter(logger.NewLogger(io.Discard, io.Discard, false), maybe.Maybe[string]{}),
+		provider.Tracer("brewkit"),
+	)
+
+	v := api.Vertex{
+		Name: "app",
+		Stage: maybe.NewJust(api.Stage{
+			From:    "alpine",
+			Command: maybe.NewJust("true"),
+			Image:   maybe.NewJust(api.Image{Tags: []string{"app:latest"}}),
+		}),
+	}
+	err = service.Build(ctx, v, nil, nil, api.BuildParams{ForcePull: true, Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shutdown flushes batched spans to collector
+	err = provider.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := collector.spans()
+	for _, name := range []string{"build", "pre-pull", "dockerfile", "targets"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("expected %s phase span, got %v", name, spanNames(spans))
+		}
+	}
+
+	pull, ok := spans["pull"]
+	if !ok {
+		t.Fatalf("expected pull span, got %v", spanNames(spans))
+	}
+	if image := stringAttr(pull, "brewkit.image"); image != "alpine" && image != "dockerfile-image" {
+		t.Errorf("unexpected image of pull span %q", image)
+	}
+
+	target, ok := spans["target app"]
+	if !ok {
+		t.Fatalf("expected target span, got %v", spanNames(spans))
+	}
+	if cached := attr(target, "brewkit.cached"); cached == nil || !cached.GetBoolValue() {
+		t.Errorf("expected cached target, got %v", cached)
+	}
+	if status := stringAttr(target, "brewkit.status"); status != "cached" {
+		t.Errorf("expected cached status, got %q", status)
+	}
+	tags := attr(target, "brewkit.image.tags")
+	if tags == nil || len(tags.GetArrayValue().GetValues()) != 1 || tags.GetArrayValue().GetValues()[0].GetStringValue() != "app:latest" {
+		t.Errorf("expected image tags of target, got %v", tags)
+	}
+}
+
+// cachedDockerClient completes all builds from cache
+type cachedDockerClient struct{}
+
+func (cachedDockerClient) Build(context.Context, dockerfile.Dockerfile, docker.BuildParams) (docker.BuildResult, error) {
+	return docker.BuildResult{Cached: true}, nil
+}
+
+func (cachedDockerClient) Value(context.Context, dockerfile.Dockerfile, docker.ValueParams) ([]byte, error) {
+	return nil, nil
+}
+
+func (cachedDockerClient) PullImage(context.Context, string) error {
+	return nil
+}
+
+func (cachedDockerClient) ListImages(context.Context, []string) ([]docker.Image, error) {
+	return nil, nil
+}
+
+func (cachedDockerClient) BuildImage(context.Context, dockerfile.Dockerfile, docker.BuildImageParams) (docker.BuildResult, error) {
+	return docker.BuildResult{Cached: true}, nil
+}
+
+func (cachedDockerClient) ClearCache(context.Context, docker.ClearCacheParams) error {
+	return nil
+}
+
+// collector is in-process OTLP gRPC collector that records exported spans by name
+type collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	address string
+
+	mu       sync.Mutex
+	received map[string]*tracev1.Span
+}
+
+func startCollector(t *testing.T) *collector {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{
+		address:  listener.Addr().String(),
+		received: map[string]*tracev1.Span{},
+	}
+
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, c)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return c
+}
+
+func (c *collector) Export(_ context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, resourceSpans := range req.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				c.received[span.Name] = span
+			}
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (c *collector) spans() map[string]*tracev1.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make(map[string]*tracev1.Span, len(c.received))
+	for name, span := range c.received {
+		res[name] = span
+	}
+	return res
+}
+
+func spanNames(spans map[string]*tracev1.Span) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
+
+func attr(span *tracev1.Span, key string) *commonv1.AnyValue {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func stringAttr(span *tracev1.Span, key string) string {
+	return attr(span, key).GetStringValue()
+}
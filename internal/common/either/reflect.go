@@ -0,0 +1,10 @@
+package either
+
+import (
+	"reflect"
+)
+
+// InnerTypes returns types of left and right values, so reflection based code can inspect Either without access to its fields
+func (e Either[L, R]) InnerTypes() (l, r reflect.Type) {
+	return reflect.TypeOf((*L)(nil)).Elem(), reflect.TypeOf((*R)(nil)).Elem()
+}
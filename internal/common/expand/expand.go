@@ -0,0 +1,82 @@
+package expand
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Expand replaces ${name} and $name references in s with values from mapping.
+// $$ is replaced with literal $, $ followed by symbol that can not start name is left as is
+func Expand(s string, mapping func(name string) (string, error)) (string, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end == -1 {
+				return "", errors.Errorf("unterminated reference in %q, use $$ for literal $", s)
+			}
+
+			// Braced name may contain any symbols, e.g. var names with dashes
+			name := s[i+2 : i+2+end]
+			if name == "" {
+				return "", errors.Errorf("empty reference ${} in %q, use $$ for literal $", s)
+			}
+
+			value, err := mapping(name)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+
+			i += 2 + end
+		case isNameStart(next):
+			end := i + 1
+			for end < len(s) && isNameSymbol(s[end]) {
+				end++
+			}
+
+			value, err := mapping(s[i+1 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+
+			i = end - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// References returns names referenced in s in order of appearance
+func References(s string) ([]string, error) {
+	var references []string
+	_, err := Expand(s, func(name string) (string, error) {
+		references = append(references, name)
+		return "", nil
+	})
+	return references, err
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameSymbol(c byte) bool {
+	return isNameStart(c) || '0' <= c && c <= '9'
+}
@@ -0,0 +1,78 @@
+package expand
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestExpand(t *testing.T) {
+	values := map[string]string{
+		"version":    "1.0.0",
+		"git-commit": "5f2c1a9",
+		"GOOS":       "linux",
+	}
+	mapping := func(name string) (string, error) {
+		value, ok := values[name]
+		if !ok {
+			return "", errors.Errorf("undefined %s", name)
+		}
+		return value, nil
+	}
+
+	testCases := []struct {
+		name     string
+		s        string
+		expected string
+		err      string
+	}{
+		{name: "no references", s: "go build ./...", expected: "go build ./..."},
+		{name: "name", s: "$version", expected: "1.0.0"},
+		{name: "braces", s: "${version}", expected: "1.0.0"},
+		{name: "braces with dash", s: "${git-commit}", expected: "5f2c1a9"},
+		{name: "name ends at dash", s: "$GOOS-amd64", expected: "linux-amd64"},
+		{name: "adjacent text", s: "app-${version}.tar", expected: "app-1.0.0.tar"},
+		{name: "adjacent references", s: "$GOOS${version}$GOOS", expected: "linux1.0.0linux"},
+		{name: "escape", s: "echo $$HOME", expected: "echo $HOME"},
+		{name: "escaped braces", s: "echo $${HOME}", expected: "echo ${HOME}"},
+		{name: "escape before reference", s: "$$$version", expected: "$1.0.0"},
+		{name: "subshell is left as is", s: "$(pwd)", expected: "$(pwd)"},
+		{name: "trailing dollar", s: "cost 5$", expected: "cost 5$"},
+		{name: "digit after dollar", s: "$1", expected: "$1"},
+		{name: "undefined name", s: "echo $HOME", err: "undefined HOME"},
+		{name: "undefined braces", s: "echo ${HOME}", err: "undefined HOME"},
+		{name: "unterminated braces", s: "${version", err: "unterminated reference"},
+		{name: "empty braces", s: "${}", err: "empty reference"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := Expand(tc.s, mapping)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	references, err := References("$$HOME ${git-commit}/$GOOS-$version $(pwd)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"git-commit", "GOOS", "version"}
+	if strings.Join(references, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected references %q, got %q", expected, references)
+	}
+}
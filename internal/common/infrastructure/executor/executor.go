@@ -16,6 +16,7 @@ type RunParams struct {
 	Stdin  maybe.Maybe[io.Reader]
 	Stdout maybe.Maybe[io.Writer]
 	Stderr maybe.Maybe[io.Writer]
+	Env    []string // Appended to env of executor
 }
 
 type Executor interface {
@@ -57,7 +58,7 @@ func (e *executor) Run(ctx context.Context, args Args, params RunParams) (err er
 		return os.Stderr
 	})
 
-	cmd.Env = e.options.env
+	cmd.Env = append(append([]string{}, e.options.env...), params.Env...)
 
 	err = e.logArgs(args)
 	if err != nil {
@@ -0,0 +1,10 @@
+package maybe
+
+import (
+	"reflect"
+)
+
+// InnerType returns type of value that Maybe holds, so reflection based code can inspect Maybe without access to its fields
+func (m Maybe[T]) InnerType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
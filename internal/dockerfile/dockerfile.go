@@ -35,6 +35,7 @@ func (d Dockerfile) Format() string {
 
 type Stage struct {
 	From         string
+	Platform     maybe.Maybe[string]
 	As           maybe.Maybe[string]
 	Instructions []Instruction
 }
@@ -49,7 +50,12 @@ func (s Stage) Format() string {
 		asBlock = fmt.Sprintf("as %s", maybe.Just(s.As))
 	}
 
-	return fmt.Sprintf("FROM %s %s\n%s", s.From, asBlock, instructions)
+	var platformBlock string
+	if maybe.Valid(s.Platform) {
+		platformBlock = fmt.Sprintf("--platform=%s ", maybe.Just(s.Platform))
+	}
+
+	return fmt.Sprintf("FROM %s%s %s\n%s", platformBlock, s.From, asBlock, instructions)
 }
 
 type Instruction interface {
@@ -144,6 +150,7 @@ func (m MountBind) FormatMount() string {
 type MountCache struct {
 	ID       maybe.Maybe[string]
 	Target   string
+	Sharing  maybe.Maybe[string]
 	ReadOnly maybe.Maybe[bool]
 	From     maybe.Maybe[string]
 	Source   maybe.Maybe[string]
@@ -158,8 +165,16 @@ func (m MountCache) FormatMount() string {
 	s.addKV("type", "cache")
 	s.addKV("target", m.Target)
 
+	if maybe.Valid(m.ID) {
+		s.addKV("id", maybe.Just(m.ID))
+	}
+
+	if maybe.Valid(m.Sharing) {
+		s.addKV("sharing", maybe.Just(m.Sharing))
+	}
+
 	if maybe.Valid(m.ReadOnly) {
-		s.addKV("source", strconv.FormatBool(maybe.Just(m.ReadOnly)))
+		s.addKV("readonly", strconv.FormatBool(maybe.Just(m.ReadOnly)))
 	}
 
 	if maybe.Valid(m.From) {
@@ -185,6 +200,24 @@ func (m MountCache) FormatMount() string {
 	return s.formatSettings()
 }
 
+type MountTmpfs struct {
+	Target string
+	Size   maybe.Maybe[string]
+}
+
+func (m MountTmpfs) FormatMount() string {
+	s := settings{}
+
+	s.addKV("type", "tmpfs")
+	s.addKV("target", m.Target)
+
+	if maybe.Valid(m.Size) {
+		s.addKV("size", maybe.Just(m.Size))
+	}
+
+	return s.formatSettings()
+}
+
 type MountSSH struct {
 	ID       maybe.Maybe[string]
 	Target   maybe.Maybe[string]
@@ -199,6 +232,10 @@ func (m MountSSH) FormatMount() string {
 
 	s.addKV("type", "ssh")
 
+	if maybe.Valid(m.ID) {
+		s.addKV("id", maybe.Just(m.ID))
+	}
+
 	if maybe.Valid(m.Target) {
 		s.addKV("target", maybe.Just(m.Target))
 	}
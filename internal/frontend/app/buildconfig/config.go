@@ -2,12 +2,14 @@ package buildconfig
 
 import (
 	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/frontend/app/config"
 )
 
 type Config struct {
-	APIVersion string
-	Vars       []VarData
-	Targets    []TargetData
+	APIVersion  string
+	Vars        []VarData
+	Targets     []TargetData
+	RemoteCache maybe.Maybe[[]config.RemoteCache] // Overrides remote cache from host config
 }
 
 type VarData struct {
@@ -17,17 +19,21 @@ type VarData struct {
 	WorkDir  string
 	Env      map[string]string
 	Cache    []Cache
+	Tmpfs    []Tmpfs
 	Copy     []Copy
 	Secrets  []Secret
 	Network  maybe.Maybe[string]
 	SSH      maybe.Maybe[SSH]
 	Command  string
+	UseCache bool
 }
 
 type TargetData struct {
-	Name      string
-	DependsOn []string
-	Stage     maybe.Maybe[StageData]
+	Name        string
+	Description string
+	Hidden      bool // Internal helper target, which is not listed by default
+	DependsOn   []string
+	Stage       maybe.Maybe[StageData]
 }
 
 type StageData struct {
@@ -36,19 +42,31 @@ type StageData struct {
 	Command  maybe.Maybe[string]
 	SSH      maybe.Maybe[SSH]
 	Cache    []Cache
+	Tmpfs    []Tmpfs
 	Copy     []Copy
+	Mounts   []Mount
 	Secrets  []Secret
 	Platform maybe.Maybe[string]
 	WorkDir  string
 	Network  maybe.Maybe[string]
-	Output   maybe.Maybe[Output]
+	Outputs  []Output
+	Image    maybe.Maybe[Image]
 }
 
-type SSH struct{}
+type SSH struct {
+	ID maybe.Maybe[string] // ID of ssh agent from host config, default agent is used when empty
+}
 
 type Cache struct {
-	ID   string
-	Path string
+	ID       string
+	Path     string
+	Sharing  maybe.Maybe[string] // shared, private or locked
+	UID      maybe.Maybe[int]
+	GID      maybe.Maybe[int]
+	Mode     maybe.Maybe[string] // Octal file mode of cache directory
+	ReadOnly bool
+	From     maybe.Maybe[string] // Target or image to seed cache with
+	Source   maybe.Maybe[string] // Path in From
 }
 
 type Copy struct {
@@ -57,6 +75,18 @@ type Copy struct {
 	Dst  string
 }
 
+type Tmpfs struct {
+	Path string
+	Size maybe.Maybe[string] // Size limit in bytes with optional unit suffix: 512m, 1g
+}
+
+// Mount binds local path or path of other target into RUN
+type Mount struct {
+	From maybe.Maybe[string]
+	Src  string
+	Dst  string
+}
+
 type Secret struct {
 	ID   string
 	Path string
@@ -65,4 +95,13 @@ type Secret struct {
 type Output struct {
 	Artifact string
 	Local    string
+	Rename   maybe.Maybe[string] // Name of artifact in local destination
+	Type     maybe.Maybe[string] // Export type: local, tar or oci
+}
+
+type Image struct {
+	Tags   []string
+	Labels map[string]string
+	Push   bool
+	Load   bool
 }
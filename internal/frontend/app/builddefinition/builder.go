@@ -3,16 +3,13 @@ package builddefinition
 import (
 	"github.com/pkg/errors"
 
-	"github.com/ispringtech/brewkit/internal/backend/api"
-	"github.com/ispringtech/brewkit/internal/common/maybe"
-	"github.com/ispringtech/brewkit/internal/common/slices"
 	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
 	"github.com/ispringtech/brewkit/internal/frontend/app/config"
 	"github.com/ispringtech/brewkit/internal/frontend/app/version"
 )
 
 type Builder interface {
-	Build(config buildconfig.Config, secrets []config.Secret) (Definition, error)
+	Build(config buildconfig.Config, secrets []config.Secret, sshAgents []config.SSHAgent) (Definition, error)
 }
 
 func NewBuilder() Builder {
@@ -21,17 +18,22 @@ func NewBuilder() Builder {
 
 type builder struct{}
 
-func (builder builder) Build(c buildconfig.Config, secrets []config.Secret) (Definition, error) {
+func (builder builder) Build(c buildconfig.Config, secrets []config.Secret, sshAgents []config.SSHAgent) (Definition, error) {
 	if c.APIVersion != version.APIVersionV1 {
 		return Definition{}, errors.Wrapf(ErrUnsupportedAPIVersion, "version: %s", c.APIVersion)
 	}
 
-	vertexes, err := newVertexGraphBuilder(secrets, c.Targets).graphVertexes()
+	vertexes, err := newVertexGraphBuilder(secrets, sshAgents, c.Targets).graphVertexes()
 	if err != nil {
 		return Definition{}, err
 	}
 
-	vars, err := builder.variables(c.Vars, secrets)
+	err = checkVertexReferences(vertexes, c.Vars)
+	if err != nil {
+		return Definition{}, err
+	}
+
+	vars, err := newVarGraphBuilder(secrets, sshAgents, c.Vars, vertexes).orderedVars()
 	if err != nil {
 		return Definition{}, err
 	}
@@ -41,34 +43,3 @@ func (builder builder) Build(c buildconfig.Config, secrets []config.Secret) (Def
 		Vars:     vars,
 	}, err
 }
-
-func (builder builder) variables(vars []buildconfig.VarData, secrets []config.Secret) ([]api.Var, error) {
-	return slices.MapErr(vars, func(v buildconfig.VarData) (api.Var, error) {
-		mappedSecrets, err := mapSecrets(v.Secrets, secrets)
-		if err != nil {
-			return api.Var{}, errors.Wrapf(err, "failed to map secrets in %s variable", v.Name)
-		}
-
-		return api.Var{
-			Name: v.Name,
-			From: v.From,
-			Platform: maybe.Map(v.Platform, func(p string) string {
-				return p
-			}),
-			WorkDir: v.WorkDir,
-			Env:     v.Env,
-			Cache:   slices.Map(v.Cache, mapCache),
-			Copy:    slices.Map(v.Copy, mapCopy),
-			Network: maybe.Map(v.Network, func(n string) api.Network {
-				return api.Network{
-					Network: n,
-				}
-			}),
-			SSH: maybe.Map(v.SSH, func(s buildconfig.SSH) api.SSH {
-				return api.SSH{}
-			}),
-			Secrets: mappedSecrets,
-			Command: v.Command,
-		}, nil
-	})
-}
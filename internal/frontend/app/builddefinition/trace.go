@@ -10,9 +10,12 @@ import (
 )
 
 const (
-	from          = "from"
-	deps          = "deps"
-	copyDirective = "copy"
+	from           = "from"
+	deps           = "deps"
+	copyDirective  = "copy"
+	cacheDirective = "cache"
+	mountDirective = "mount"
+	reference      = "ref"
 )
 
 type traceEntry struct {
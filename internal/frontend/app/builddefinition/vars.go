@@ -0,0 +1,388 @@
+package builddefinition
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/common/either"
+	"github.com/ispringtech/brewkit/internal/common/expand"
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/common/slices"
+	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
+	"github.com/ispringtech/brewkit/internal/frontend/app/config"
+)
+
+func newVarGraphBuilder(
+	secrets []config.Secret,
+	sshAgents []config.SSHAgent,
+	vars []buildconfig.VarData,
+	vertexes []api.Vertex,
+) *varGraphBuilder {
+	return &varGraphBuilder{
+		varsMap: maps.FromSlice(vars, func(v buildconfig.VarData) (string, buildconfig.VarData) {
+			return v.Name, v
+		}),
+		vertexesMap: maps.FromSlice(vertexes, func(v api.Vertex) (string, api.Vertex) {
+			return v.Name, v
+		}),
+		visitedVars: maps.Set[string]{},
+		trace:       trace{},
+		secrets:     secrets,
+		sshAgents:   sshAgents,
+	}
+}
+
+// varGraphBuilder orders vars so each var goes after vars which values it uses
+type varGraphBuilder struct {
+	varsMap     map[string]buildconfig.VarData
+	vertexesMap map[string]api.Vertex
+	visitedVars maps.Set[string]
+	sortedVars  []api.Var
+
+	trace     trace // Trace to detect cyclic graphs
+	secrets   []config.Secret
+	sshAgents []config.SSHAgent
+}
+
+func (builder *varGraphBuilder) orderedVars() ([]api.Var, error) {
+	names := maps.ToSlice(builder.varsMap, func(name string, _ buildconfig.VarData) string {
+		return name
+	})
+	// Sort names to make order of independent vars stable
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := builder.recursiveVar(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "vars solve error")
+		}
+	}
+
+	return builder.sortedVars, nil
+}
+
+func (builder *varGraphBuilder) recursiveVar(name string) error {
+	if builder.visitedVars.Has(name) {
+		return nil
+	}
+
+	if builder.trace.has(name) {
+		return errors.Errorf("recursive vars detected by '%s' var, trace: %s", name, builder.trace.String())
+	}
+
+	v, ok := builder.varsMap[name]
+	if !ok {
+		return errors.Errorf("logic error: VarData for Var %s not found", name)
+	}
+
+	copyDirs, err := builder.walkCopy(v)
+	if err != nil {
+		return err
+	}
+
+	caches, err := builder.walkCache(v)
+	if err != nil {
+		return err
+	}
+
+	tmpfs, err := slices.MapErr(v.Tmpfs, mapTmpfs)
+	if err != nil {
+		return errors.Wrapf(err, "invalid tmpfs in %s variable", v.Name)
+	}
+
+	mappedSecrets, err := mapSecrets(v.Secrets, builder.secrets)
+	if err != nil {
+		return errors.Wrapf(err, "failed to map secrets in %s variable", v.Name)
+	}
+
+	ssh, err := maybe.MapErr(v.SSH, func(ssh buildconfig.SSH) (api.SSH, error) {
+		return mapSSH(ssh, builder.sshAgents)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to map ssh in %s variable", v.Name)
+	}
+
+	network, err := mapNetwork(v.Network)
+	if err != nil {
+		return errors.Wrapf(err, "invalid network in %s variable", v.Name)
+	}
+
+	mappedVar := api.Var{
+		Name: v.Name,
+		From: v.From,
+		Platform: maybe.Map(v.Platform, func(p string) string {
+			return p
+		}),
+		WorkDir:  v.WorkDir,
+		Env:      v.Env,
+		Cache:    caches,
+		Tmpfs:    tmpfs,
+		Copy:     copyDirs,
+		Network:  network,
+		SSH:      ssh,
+		Secrets:  mappedSecrets,
+		Command:  v.Command,
+		UseCache: v.UseCache,
+	}
+
+	mappedVar.DependsOn, err = builder.walkReferences(mappedVar)
+	if err != nil {
+		return err
+	}
+
+	builder.visitedVars.Add(name)
+	builder.sortedVars = append(builder.sortedVars, mappedVar)
+
+	return nil
+}
+
+// solves 'copy' from targets
+func (builder *varGraphBuilder) walkCopy(v buildconfig.VarData) ([]api.Copy, error) {
+	return slices.MapErr(v.Copy, func(c buildconfig.Copy) (api.Copy, error) {
+		if !maybe.Valid(c.From) {
+			return api.Copy{
+				Src: c.Src,
+				Dst: c.Dst,
+			}, nil
+		}
+
+		copyFrom := maybe.Just(c.From)
+
+		vertex, found := builder.vertexesMap[copyFrom]
+		if !found {
+			return api.Copy{
+				Src:  c.Src,
+				Dst:  c.Dst,
+				From: maybe.NewJust(either.NewRight[*api.Vertex, string](copyFrom)),
+			}, nil
+		}
+
+		if _, conflicts := builder.vertexesMap[v.Name]; conflicts {
+			// Var copied from targets shares dockerfile with targets stages
+			return api.Copy{}, errors.Errorf("var %s copies from target %s, so var name should not match any target", v.Name, copyFrom)
+		}
+
+		return api.Copy{
+			Src:  c.Src,
+			Dst:  c.Dst,
+			From: maybe.NewJust(either.NewLeft[*api.Vertex, string](&vertex)),
+		}, nil
+	})
+}
+
+// solves cache 'from' targets
+func (builder *varGraphBuilder) walkCache(v buildconfig.VarData) ([]api.Cache, error) {
+	return slices.MapErr(v.Cache, func(c buildconfig.Cache) (api.Cache, error) {
+		cache, err := mapCache(c)
+		if err != nil {
+			return api.Cache{}, errors.Wrapf(err, "invalid cache %s in %s variable", c.ID, v.Name)
+		}
+
+		if !maybe.Valid(c.From) {
+			return cache, nil
+		}
+
+		cacheFrom := maybe.Just(c.From)
+
+		vertex, found := builder.vertexesMap[cacheFrom]
+		if !found {
+			cache.From = maybe.NewJust(either.NewRight[*api.Vertex, string](cacheFrom))
+			return cache, nil
+		}
+
+		if _, conflicts := builder.vertexesMap[v.Name]; conflicts {
+			// Var seeded from targets shares dockerfile with targets stages
+			return api.Cache{}, errors.Errorf("var %s seeds cache from target %s, so var name should not match any target", v.Name, cacheFrom)
+		}
+
+		cache.From = maybe.NewJust(either.NewLeft[*api.Vertex, string](&vertex))
+		return cache, nil
+	})
+}
+
+// solves references to other vars in var itself and in targets from which var copies
+func (builder *varGraphBuilder) walkReferences(v api.Var) ([]string, error) {
+	references := maps.Set[string]{}
+
+	for _, s := range varExpandables(v) {
+		names, err := expand.References(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "var %s", v.Name)
+		}
+
+		for _, name := range names {
+			if _, known := builder.varsMap[name]; !known {
+				return nil, errors.Errorf("var %s: reference to unknown var %s", v.Name, name)
+			}
+			references.Add(name)
+		}
+	}
+
+	builder.trace.push(traceEntry{
+		name:      v.Name,
+		directive: reference,
+	})
+	defer builder.trace.pop()
+
+	visitedVertexes := maps.Set[string]{}
+	for _, source := range v.Sources() {
+		// References in targets validated by checkVertexReferences, so only known vars added
+		source.
+			MapLeft(func(vertex *api.Vertex) {
+				builder.addVertexReferences(references, *vertex, visitedVertexes)
+			})
+	}
+
+	dependsOn := maps.ToSlice(references, func(name string, _ struct{}) string {
+		return name
+	})
+	sort.Strings(dependsOn)
+
+	for _, dependency := range dependsOn {
+		err := builder.recursiveVar(dependency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dependsOn, nil
+}
+
+func (builder *varGraphBuilder) addVertexReferences(references maps.Set[string], v api.Vertex, visited maps.Set[string]) {
+	if visited.Has(v.Name) {
+		return
+	}
+	visited.Add(v.Name)
+
+	if maybe.Valid(v.From) {
+		builder.addVertexReferences(references, *maybe.Just(v.From), visited)
+	}
+
+	if maybe.Valid(v.Stage) {
+		stage := maybe.Just(v.Stage)
+
+		for _, s := range stageExpandables(stage) {
+			builder.addReferences(references, s)
+		}
+
+		for _, source := range stage.Sources() {
+			source.
+				MapLeft(func(sourceV *api.Vertex) {
+					builder.addVertexReferences(references, *sourceV, visited)
+				})
+		}
+	}
+
+	for _, childVertex := range v.DependsOn {
+		builder.addVertexReferences(references, childVertex, visited)
+	}
+}
+
+// addReferences adds names of known vars referenced in s
+func (builder *varGraphBuilder) addReferences(references maps.Set[string], s string) {
+	names, _ := expand.References(s)
+	for _, name := range names {
+		if _, known := builder.varsMap[name]; known {
+			references.Add(name)
+		}
+	}
+}
+
+// checkVertexReferences checks that all vars referenced by vertexes defined, so typos found before build
+func checkVertexReferences(vertexes []api.Vertex, vars []buildconfig.VarData) error {
+	varNames := maps.Set[string]{}
+	for _, v := range vars {
+		varNames.Add(v.Name)
+	}
+
+	for _, v := range vertexes {
+		if !maybe.Valid(v.Stage) {
+			continue
+		}
+
+		for _, s := range stageExpandables(maybe.Just(v.Stage)) {
+			names, err := expand.References(s)
+			if err != nil {
+				return errors.Wrapf(err, "target %s", v.Name)
+			}
+
+			for _, name := range names {
+				if !varNames.Has(name) {
+					return errors.Errorf("target %s: reference to unknown var %s", v.Name, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// stageExpandables returns stage fields in which vars are expanded
+func stageExpandables(stage api.Stage) []string {
+	res := []string{stage.WorkDir}
+	for _, value := range stage.Env {
+		res = append(res, value)
+	}
+	for _, c := range stage.Copy {
+		res = append(res, c.Src, c.Dst)
+	}
+	for _, m := range stage.Mounts {
+		res = append(res, m.Src, m.Dst)
+	}
+	for _, t := range stage.Tmpfs {
+		res = append(res, t.Path)
+	}
+	for _, c := range stage.Cache {
+		res = append(res, c.Path)
+		if maybe.Valid(c.Source) {
+			res = append(res, maybe.Just(c.Source))
+		}
+	}
+	for _, s := range stage.Secrets {
+		res = append(res, s.MountPath)
+	}
+	if maybe.Valid(stage.Command) {
+		res = append(res, maybe.Just(stage.Command))
+	}
+	for _, output := range stage.Outputs {
+		res = append(res, output.Artifact, output.Local)
+		if maybe.Valid(output.Rename) {
+			res = append(res, maybe.Just(output.Rename))
+		}
+	}
+	if maybe.Valid(stage.Image) {
+		image := maybe.Just(stage.Image)
+		res = append(res, image.Tags...)
+		for _, value := range image.Labels {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+// varExpandables returns var fields in which vars are expanded
+func varExpandables(v api.Var) []string {
+	res := []string{v.WorkDir, v.Command}
+	for _, value := range v.Env {
+		res = append(res, value)
+	}
+	for _, c := range v.Copy {
+		res = append(res, c.Src, c.Dst)
+	}
+	for _, t := range v.Tmpfs {
+		res = append(res, t.Path)
+	}
+	for _, c := range v.Cache {
+		res = append(res, c.Path)
+		if maybe.Valid(c.Source) {
+			res = append(res, maybe.Just(c.Source))
+		}
+	}
+	for _, s := range v.Secrets {
+		res = append(res, s.MountPath)
+	}
+	return res
+}
@@ -1,6 +1,9 @@
 package builddefinition
 
 import (
+	"regexp"
+	"strconv"
+
 	"github.com/pkg/errors"
 	stdslices "golang.org/x/exp/slices"
 
@@ -13,7 +16,11 @@ import (
 	"github.com/ispringtech/brewkit/internal/frontend/app/config"
 )
 
-func newVertexGraphBuilder(secrets []config.Secret, targets []buildconfig.TargetData) *vertexGraphBuilder {
+func newVertexGraphBuilder(
+	secrets []config.Secret,
+	sshAgents []config.SSHAgent,
+	targets []buildconfig.TargetData,
+) *vertexGraphBuilder {
 	return &vertexGraphBuilder{
 		visitedVertexes: map[string]api.Vertex{},
 		vertexesSet: maps.SetFromSlice(targets, func(t buildconfig.TargetData) string {
@@ -22,8 +29,9 @@ func newVertexGraphBuilder(secrets []config.Secret, targets []buildconfig.Target
 		targetsMap: maps.FromSlice(targets, func(t buildconfig.TargetData) (string, buildconfig.TargetData) {
 			return t.Name, t
 		}),
-		trace:   trace{},
-		secrets: secrets,
+		trace:     trace{},
+		secrets:   secrets,
+		sshAgents: sshAgents,
 	}
 }
 
@@ -32,8 +40,9 @@ type vertexGraphBuilder struct {
 	vertexesSet     maps.Set[string]
 	targetsMap      map[string]buildconfig.TargetData
 
-	trace   trace // Trace to detect cyclic graphs
-	secrets []config.Secret
+	trace     trace // Trace to detect cyclic graphs
+	secrets   []config.Secret
+	sshAgents []config.SSHAgent
 }
 
 func (builder *vertexGraphBuilder) graphVertexes() ([]api.Vertex, error) {
@@ -67,6 +76,8 @@ func (builder *vertexGraphBuilder) recursiveGraph(vertex string) (api.Vertex, er
 	var (
 		fromV     maybe.Maybe[*api.Vertex]
 		copyDirs  []api.Copy
+		caches    []api.Cache
+		mounts    []api.Mount
 		dependsOn []api.Vertex
 	)
 
@@ -91,6 +102,22 @@ func (builder *vertexGraphBuilder) recursiveGraph(vertex string) (api.Vertex, er
 				return api.Vertex{}, err
 			}
 		}
+
+		if len(stage.Cache) != 0 {
+			var err error
+			caches, err = builder.walkCache(vertex, stage.Cache)
+			if err != nil {
+				return api.Vertex{}, err
+			}
+		}
+
+		if len(stage.Mounts) != 0 {
+			var err error
+			mounts, err = builder.walkMount(vertex, stage.Mounts)
+			if err != nil {
+				return api.Vertex{}, err
+			}
+		}
 	}
 
 	if len(t.DependsOn) != 0 {
@@ -104,7 +131,11 @@ func (builder *vertexGraphBuilder) recursiveGraph(vertex string) (api.Vertex, er
 	var stage maybe.Maybe[api.Stage]
 
 	stage, err := maybe.MapErr(t.Stage, func(s buildconfig.StageData) (api.Stage, error) {
-		return mapStage(t.Name, maybe.Just(t.Stage), copyDirs, builder.secrets)
+		return mapStage(t.Name, maybe.Just(t.Stage), stageSources{
+			copyDirs: copyDirs,
+			caches:   caches,
+			mounts:   mounts,
+		}, builder.secrets, builder.sshAgents)
 	})
 	if err != nil {
 		return api.Vertex{}, err
@@ -173,6 +204,80 @@ func (builder *vertexGraphBuilder) walkCopy(vertexName string, copyDirs []buildc
 	})
 }
 
+// solves 'from' of cache
+func (builder *vertexGraphBuilder) walkCache(vertexName string, caches []buildconfig.Cache) ([]api.Cache, error) {
+	builder.trace.push(traceEntry{
+		name:      vertexName,
+		directive: cacheDirective,
+	})
+	defer builder.trace.pop()
+
+	return slices.MapErr(caches, func(c buildconfig.Cache) (api.Cache, error) {
+		cache, err := mapCache(c)
+		if err != nil {
+			return api.Cache{}, errors.Wrapf(err, "invalid cache %s in %s target", c.ID, vertexName)
+		}
+
+		if !maybe.Valid(c.From) {
+			return cache, nil
+		}
+
+		cacheFrom := maybe.Just(c.From)
+
+		if !builder.vertexesSet.Has(cacheFrom) {
+			cache.From = maybe.NewJust(either.NewRight[*api.Vertex, string](cacheFrom))
+			return cache, nil
+		}
+
+		vertex, err := builder.recursiveGraph(cacheFrom)
+		if err != nil {
+			return api.Cache{}, err
+		}
+
+		cache.From = maybe.NewJust(either.NewLeft[*api.Vertex, string](&vertex))
+		return cache, nil
+	})
+}
+
+// solves 'from' of mount
+func (builder *vertexGraphBuilder) walkMount(vertexName string, mounts []buildconfig.Mount) ([]api.Mount, error) {
+	builder.trace.push(traceEntry{
+		name:      vertexName,
+		directive: mountDirective,
+	})
+	defer builder.trace.pop()
+
+	return slices.MapErr(mounts, func(m buildconfig.Mount) (api.Mount, error) {
+		if !maybe.Valid(m.From) {
+			return api.Mount{
+				Src: m.Src,
+				Dst: m.Dst,
+			}, nil
+		}
+
+		mountFrom := maybe.Just(m.From)
+
+		if !builder.vertexesSet.Has(mountFrom) {
+			return api.Mount{
+				Src:  m.Src,
+				Dst:  m.Dst,
+				From: maybe.NewJust(either.NewRight[*api.Vertex, string](mountFrom)),
+			}, nil
+		}
+
+		vertex, err := builder.recursiveGraph(mountFrom)
+		if err != nil {
+			return api.Mount{}, err
+		}
+
+		return api.Mount{
+			Src:  m.Src,
+			Dst:  m.Dst,
+			From: maybe.NewJust(either.NewLeft[*api.Vertex, string](&vertex)),
+		}, nil
+	})
+}
+
 // solves 'dependsOn' dependencies
 func (builder *vertexGraphBuilder) walkDependsOn(vertexName string, t buildconfig.TargetData) ([]api.Vertex, error) {
 	builder.trace.push(traceEntry{
@@ -191,17 +296,61 @@ func (builder *vertexGraphBuilder) walkDependsOn(vertexName string, t buildconfi
 	})
 }
 
+// stageSources are stage directives with resolved targets
+type stageSources struct {
+	copyDirs []api.Copy
+	caches   []api.Cache
+	mounts   []api.Mount
+}
+
 func mapStage(
 	stageName string,
 	s buildconfig.StageData,
-	copyDirs []api.Copy,
+	sources stageSources,
 	secrets []config.Secret,
+	sshAgents []config.SSHAgent,
 ) (api.Stage, error) {
 	mappedSecrets, err := mapSecrets(s.Secrets, secrets)
 	if err != nil {
 		return api.Stage{}, errors.Wrapf(err, "failed to map secrets in %s stage", stageName)
 	}
 
+	ssh, err := maybe.MapErr(s.SSH, func(ssh buildconfig.SSH) (api.SSH, error) {
+		return mapSSH(ssh, sshAgents)
+	})
+	if err != nil {
+		return api.Stage{}, errors.Wrapf(err, "failed to map ssh in %s stage", stageName)
+	}
+
+	if !maybe.Valid(s.Command) {
+		err = commandlessMountsError(stageName, len(sources.mounts) > 0, len(s.Tmpfs) > 0)
+		if err != nil {
+			return api.Stage{}, err
+		}
+	}
+
+	tmpfs, err := slices.MapErr(s.Tmpfs, mapTmpfs)
+	if err != nil {
+		return api.Stage{}, errors.Wrapf(err, "invalid tmpfs in %s stage", stageName)
+	}
+
+	network, err := mapNetwork(s.Network)
+	if err != nil {
+		return api.Stage{}, errors.Wrapf(err, "invalid network in %s stage", stageName)
+	}
+
+	outputs, err := slices.MapErr(s.Outputs, mapOutput)
+	if err != nil {
+		return api.Stage{}, errors.Wrapf(err, "failed to map output in %s stage", stageName)
+	}
+
+	if maybe.Valid(s.Image) {
+		image := maybe.Just(s.Image)
+		if (image.Push || image.Load) && len(image.Tags) == 0 {
+			return api.Stage{}, errors.Errorf("image of %s stage should have tags to be pushed or loaded", stageName)
+		}
+	}
+
 	return api.Stage{
 		From: s.From,
 		Platform: maybe.Map(s.Platform, func(p string) string {
@@ -209,40 +358,139 @@ func mapStage(
 		}),
 		WorkDir: s.WorkDir,
 		Env:     s.Env,
-		Cache:   slices.Map(s.Cache, mapCache),
-		Copy:    copyDirs,
-		Network: maybe.Map(s.Network, func(n string) api.Network {
-			return api.Network{
-				Network: n,
-			}
-		}),
-		SSH: maybe.Map(s.SSH, func(s buildconfig.SSH) api.SSH {
-			return api.SSH{}
-		}),
+		Cache:   sources.caches,
+		Tmpfs:   tmpfs,
+		Copy:    sources.copyDirs,
+		Mounts:  sources.mounts,
+		Network: network,
+		SSH:     ssh,
 		Secrets: mappedSecrets,
 		Command: s.Command,
-		Output: maybe.Map(s.Output, func(o buildconfig.Output) api.Output {
-			return api.Output{
-				Artifact: o.Artifact,
-				Local:    o.Local,
+		Outputs: outputs,
+		Image: maybe.Map(s.Image, func(i buildconfig.Image) api.Image {
+			return api.Image{
+				Tags:   i.Tags,
+				Labels: i.Labels,
+				Push:   i.Push,
+				Load:   i.Load,
 			}
 		}),
 	}, nil
 }
 
-func mapCache(cache buildconfig.Cache) api.Cache {
-	return api.Cache{
-		ID:   cache.ID,
-		Path: cache.Path,
+// commandlessMountsError names mounts and tmpfs of stage without command, since they are available only while command runs
+func commandlessMountsError(stageName string, hasMounts, hasTmpfs bool) error {
+	const reason = "since mounts are available only while command runs"
+	switch {
+	case hasMounts && hasTmpfs:
+		return errors.Errorf("mounts and tmpfs in %s stage require command, %s", stageName, reason)
+	case hasMounts:
+		return errors.Errorf("mount in %s stage requires command, %s", stageName, reason)
+	case hasTmpfs:
+		return errors.Errorf("tmpfs in %s stage requires command, %s", stageName, reason)
+	default:
+		return nil
 	}
 }
 
-func mapCopy(c buildconfig.Copy) api.CopyVar {
-	return api.CopyVar{
-		Src:  c.Src,
-		Dst:  c.Dst,
-		From: c.From,
+func mapOutput(o buildconfig.Output) (api.Output, error) {
+	outputType := api.LocalOutput
+	if maybe.Valid(o.Type) {
+		outputType = api.OutputType(maybe.Just(o.Type))
+	}
+
+	switch outputType {
+	case api.LocalOutput, api.TarOutput, api.OCIOutput:
+	default:
+		return api.Output{}, errors.Errorf("unknown output type %s", outputType)
 	}
+
+	return api.Output{
+		Artifact: o.Artifact,
+		Local:    o.Local,
+		Rename:   o.Rename,
+		Type:     outputType,
+	}, nil
+}
+
+func mapNetwork(network maybe.Maybe[string]) (maybe.Maybe[api.Network], error) {
+	if !maybe.Valid(network) {
+		return maybe.NewNone[api.Network](), nil
+	}
+
+	n := maybe.Just(network)
+	switch n {
+	case api.DefaultNetwork, api.NoneNetwork, api.HostNetwork:
+	default:
+		return maybe.Maybe[api.Network]{}, errors.Errorf(
+			"unknown network %s, supported networks: %s, %s, %s",
+			n, api.DefaultNetwork, api.NoneNetwork, api.HostNetwork,
+		)
+	}
+
+	return maybe.NewJust(api.Network{
+		Network: n,
+	}), nil
+}
+
+// tmpfsSizeRegexp matches size in bytes with optional unit suffix supported by buildkit
+var tmpfsSizeRegexp = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+func mapTmpfs(tmpfs buildconfig.Tmpfs) (api.Tmpfs, error) {
+	if maybe.Valid(tmpfs.Size) && !tmpfsSizeRegexp.MatchString(maybe.Just(tmpfs.Size)) {
+		return api.Tmpfs{}, errors.Errorf("size %s of %s should be in bytes with optional unit suffix b, k, m or g", maybe.Just(tmpfs.Size), tmpfs.Path)
+	}
+
+	return api.Tmpfs{
+		Path: tmpfs.Path,
+		Size: tmpfs.Size,
+	}, nil
+}
+
+// mapCache maps cache options, 'from' should be resolved by caller since it may reference target
+func mapCache(cache buildconfig.Cache) (api.Cache, error) {
+	sharing, err := maybe.MapErr(cache.Sharing, func(s string) (api.CacheSharing, error) {
+		switch sharing := api.CacheSharing(s); sharing {
+		case api.SharedCache, api.PrivateCache, api.LockedCache:
+			return sharing, nil
+		default:
+			return "", errors.Errorf(
+				"unknown sharing %s, supported sharing modes: %s, %s, %s",
+				s, api.SharedCache, api.PrivateCache, api.LockedCache,
+			)
+		}
+	})
+	if err != nil {
+		return api.Cache{}, err
+	}
+
+	if maybe.Valid(cache.Mode) {
+		_, err = strconv.ParseUint(maybe.Just(cache.Mode), 8, 32)
+		if err != nil {
+			return api.Cache{}, errors.Errorf("mode %s should be octal, e.g. 0755", maybe.Just(cache.Mode))
+		}
+	}
+
+	for _, id := range []maybe.Maybe[int]{cache.UID, cache.GID} {
+		if maybe.Valid(id) && maybe.Just(id) < 0 {
+			return api.Cache{}, errors.Errorf("uid and gid should not be negative")
+		}
+	}
+
+	if maybe.Valid(cache.Source) && !maybe.Valid(cache.From) {
+		return api.Cache{}, errors.New("source requires from")
+	}
+
+	return api.Cache{
+		ID:       cache.ID,
+		Path:     cache.Path,
+		Sharing:  sharing,
+		UID:      cache.UID,
+		GID:      cache.GID,
+		Mode:     cache.Mode,
+		ReadOnly: cache.ReadOnly,
+		Source:   cache.Source,
+	}, nil
 }
 
 func mapSecrets(secrets []buildconfig.Secret, secretSrc []config.Secret) ([]api.Secret, error) {
@@ -263,3 +511,20 @@ func mapSecret(secret buildconfig.Secret, secrets []config.Secret) (api.Secret,
 		MountPath: secret.Path,
 	}, nil
 }
+
+func mapSSH(ssh buildconfig.SSH, sshAgents []config.SSHAgent) (api.SSH, error) {
+	id := maybe.MapNone(ssh.ID, func() string {
+		return api.DefaultSSHAgent
+	})
+
+	// Default agent is forwarded from SSH_AUTH_SOCK, so it may be absent in config
+	found := id == api.DefaultSSHAgent || stdslices.ContainsFunc(sshAgents, func(a config.SSHAgent) bool {
+		return a.ID == id
+	})
+	if !found {
+		return api.SSH{}, errors.Errorf("reference to unknown ssh agent %s", id)
+	}
+	return api.SSH{
+		ID: id,
+	}, nil
+}
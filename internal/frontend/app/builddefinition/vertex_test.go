@@ -0,0 +1,49 @@
+package builddefinition
+
+import (
+	"testing"
+
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
+)
+
+func TestMapStageRequiresCommandForMountsAndTmpfs(t *testing.T) {
+	const reason = ", since mounts are available only while command runs"
+	testCases := []struct {
+		name     string
+		stage    buildconfig.StageData
+		sources  stageSources
+		expected string
+	}{
+		{
+			name:     "mount",
+			sources:  stageSources{mounts: []api.Mount{{Src: "./src", Dst: "/src"}}},
+			expected: "mount in app stage requires command" + reason,
+		},
+		{
+			name:     "tmpfs",
+			stage:    buildconfig.StageData{Tmpfs: []buildconfig.Tmpfs{{Path: "/tmp"}}},
+			expected: "tmpfs in app stage requires command" + reason,
+		},
+		{
+			name:     "mount and tmpfs",
+			stage:    buildconfig.StageData{Tmpfs: []buildconfig.Tmpfs{{Path: "/tmp"}}},
+			sources:  stageSources{mounts: []api.Mount{{Src: "./src", Dst: "/src"}}},
+			expected: "mounts and tmpfs in app stage require command" + reason,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stage.From = "alpine"
+
+			_, err := mapStage("app", tc.stage, tc.sources, nil, nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if err.Error() != tc.expected {
+				t.Errorf("expected error %q, got %q", tc.expected, err)
+			}
+		})
+	}
+}
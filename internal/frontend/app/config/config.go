@@ -1,10 +1,92 @@
 package config
 
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
 type Config struct {
-	Secrets []Secret
+	Secrets     []Secret
+	SSHAgents   []SSHAgent
+	Backend     Backend
+	RemoteCache []RemoteCache
+	Tracing     maybe.Maybe[Tracing]
 }
 
 type Secret struct {
 	ID   string
 	Path string
 }
+
+// SSHAgent is named ssh agent socket or private key file which targets reference by ID
+type SSHAgent struct {
+	ID   string
+	Path string
+}
+
+// Tracing configures export of build traces to OpenTelemetry collector via OTLP gRPC
+type Tracing struct {
+	Endpoint string // host:port of collector, OTEL_EXPORTER_OTLP_* env is used when empty
+	Insecure bool   // Disable TLS
+}
+
+type BackendType string
+
+const (
+	DockerBackend   BackendType = "docker"   // Builds via docker CLI
+	BuildKitBackend BackendType = "buildkit" // Builds directly on buildkitd
+)
+
+type Backend struct {
+	Type    BackendType
+	Address maybe.Maybe[string] // Address of buildkitd for BuildKitBackend
+}
+
+type RemoteCacheType string
+
+const (
+	RegistryCache RemoteCacheType = "registry" // Cache stored in image registry
+	LocalCache    RemoteCacheType = "local"    // Cache stored in directory on host
+	InlineCache   RemoteCacheType = "inline"   // Cache embedded into pushed image
+)
+
+type RemoteCacheMode string
+
+const (
+	MinCacheMode RemoteCacheMode = "min" // Export cache only for resulting layers
+	MaxCacheMode RemoteCacheMode = "max" // Export cache for all intermediate layers
+)
+
+// RemoteCache describes backend to import build cache from and export to
+type RemoteCache struct {
+	Type     RemoteCacheType
+	Ref      string // Image reference for registry and inline cache
+	Path     string // Directory for local cache
+	Mode     RemoteCacheMode
+	ReadOnly bool // Only import cache, useful for untrusted builds
+}
+
+func ValidateRemoteCache(c RemoteCache) error {
+	switch c.Type {
+	case RegistryCache:
+		if c.Ref == "" {
+			return errors.Errorf("ref is required for %s cache", c.Type)
+		}
+	case LocalCache:
+		if c.Path == "" {
+			return errors.Errorf("path is required for %s cache", c.Type)
+		}
+	case InlineCache:
+	default:
+		return errors.Errorf("unknown cache type %s", c.Type)
+	}
+
+	switch c.Mode {
+	case MinCacheMode, MaxCacheMode:
+	default:
+		return errors.Errorf("unknown cache mode %s", c.Mode)
+	}
+
+	return nil
+}
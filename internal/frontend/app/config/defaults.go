@@ -9,10 +9,16 @@ import (
 
 const (
 	defaultConfigPath = "%s/.brewkit/config"
+
+	DefaultBuildKitAddress = "unix:///run/buildkit/buildkitd.sock"
 )
 
 var (
-	DefaultConfig = Config{}
+	DefaultConfig = Config{
+		Backend: Backend{
+			Type: DockerBackend,
+		},
+	}
 )
 
 func DefaultConfigPath() (string, error) {
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Format string
+
+const (
+	DOT     Format = "dot"
+	Mermaid Format = "mermaid"
+)
+
+func (g Graph) Render(format Format) (string, error) {
+	switch format {
+	case DOT:
+		return g.dot(), nil
+	case Mermaid:
+		return g.mermaid(), nil
+	default:
+		return "", errors.Errorf("unknown graph format %s, supported formats: %s, %s", format, DOT, Mermaid)
+	}
+}
+
+func (g Graph) dot() string {
+	b := strings.Builder{}
+	b.WriteString("digraph brewkit {\n")
+	b.WriteString("    node [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		var attrs []string
+		if !n.HasStage {
+			attrs = append(attrs, "style=dashed")
+		}
+		if n.HasOutput {
+			attrs = append(attrs, "style=\"filled,bold\"", "fillcolor=palegreen")
+		}
+
+		b.WriteString("    " + strconv.Quote(n.Name))
+		if len(attrs) > 0 {
+			b.WriteString(" [" + strings.Join(attrs, ", ") + "]")
+		}
+		b.WriteString(";\n")
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "    %s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(string(e.Kind)))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (g Graph) mermaid() string {
+	// Target names may contain symbols forbidden in mermaid ids, so nodes are referenced by index
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.Name] = fmt.Sprintf("t%d", i)
+	}
+
+	b := strings.Builder{}
+	b.WriteString("flowchart TD\n")
+
+	var outputs []string
+	for _, n := range g.Nodes {
+		label := strings.ReplaceAll(n.Name, `"`, "#quot;")
+		if n.HasStage {
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[n.Name], label)
+		} else {
+			fmt.Fprintf(&b, "    %s([\"%s\"])\n", ids[n.Name], label)
+		}
+
+		if n.HasOutput {
+			outputs = append(outputs, ids[n.Name])
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "    %s -->|%s| %s\n", ids[e.From], e.Kind, ids[e.To])
+	}
+
+	if len(outputs) > 0 {
+		b.WriteString("    classDef output fill:#98fb98,stroke-width:2px\n")
+		fmt.Fprintf(&b, "    class %s output\n", strings.Join(outputs, ","))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/common/either"
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+type EdgeKind string
+
+const (
+	FromEdge      EdgeKind = "from"
+	CopyEdge      EdgeKind = "copy"
+	CacheEdge     EdgeKind = "cache"
+	MountEdge     EdgeKind = "mount"
+	DependsOnEdge EdgeKind = "dependsOn"
+)
+
+// Graph of targets where edges are directed from target to its dependency
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+type Node struct {
+	Name      string
+	HasStage  bool // Target without stage only groups other targets
+	HasOutput bool
+}
+
+type Edge struct {
+	From string
+	To   string
+	Kind EdgeKind
+}
+
+// FromVertex walks through vertex graph and returns targets in order of appearance
+func FromVertex(v api.Vertex) Graph {
+	b := builder{
+		visited: maps.Set[string]{},
+		edges:   maps.Set[Edge]{},
+	}
+	b.walk(v)
+	return b.graph
+}
+
+type builder struct {
+	graph   Graph
+	visited maps.Set[string]
+	edges   maps.Set[Edge]
+}
+
+func (b *builder) walk(v api.Vertex) {
+	if b.visited.Has(v.Name) {
+		return
+	}
+	b.visited.Add(v.Name)
+
+	node := Node{
+		Name:     v.Name,
+		HasStage: maybe.Valid(v.Stage),
+	}
+	if node.HasStage {
+		node.HasOutput = len(maybe.Just(v.Stage).Outputs) > 0
+	}
+	b.graph.Nodes = append(b.graph.Nodes, node)
+
+	if maybe.Valid(v.From) {
+		from := maybe.Just(v.From)
+		b.addEdge(v.Name, from.Name, FromEdge)
+		b.walk(*from)
+	}
+
+	if maybe.Valid(v.Stage) {
+		stage := maybe.Just(v.Stage)
+		for _, c := range stage.Copy {
+			b.walkSource(v.Name, c.From, CopyEdge)
+		}
+		for _, c := range stage.Cache {
+			b.walkSource(v.Name, c.From, CacheEdge)
+		}
+		for _, m := range stage.Mounts {
+			b.walkSource(v.Name, m.From, MountEdge)
+		}
+	}
+
+	for _, childVertex := range v.DependsOn {
+		b.addEdge(v.Name, childVertex.Name, DependsOnEdge)
+		b.walk(childVertex)
+	}
+}
+
+func (b *builder) walkSource(name string, source maybe.Maybe[either.Either[*api.Vertex, string]], kind EdgeKind) {
+	if !maybe.Valid(source) {
+		return
+	}
+
+	maybe.Just(source).
+		MapLeft(func(sourceV *api.Vertex) {
+			b.addEdge(name, sourceV.Name, kind)
+			b.walk(*sourceV)
+		})
+}
+
+func (b *builder) addEdge(from, to string, kind EdgeKind) {
+	e := Edge{
+		From: from,
+		To:   to,
+		Kind: kind,
+	}
+	if b.edges.Has(e) {
+		return
+	}
+	b.edges.Add(e)
+	b.graph.Edges = append(b.graph.Edges, e)
+}
@@ -3,15 +3,18 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/ispringtech/brewkit/internal/backend/api"
+	"github.com/ispringtech/brewkit/internal/common/maps"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/common/slices"
 	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
 	"github.com/ispringtech/brewkit/internal/frontend/app/builddefinition"
 	appconfig "github.com/ispringtech/brewkit/internal/frontend/app/config"
+	"github.com/ispringtech/brewkit/internal/frontend/app/graph"
 )
 
 const (
@@ -20,6 +23,8 @@ const (
 
 type BuildService interface {
 	Build(ctx context.Context, p BuildParams) error
+	Plan(ctx context.Context, p PlanParams) (api.Plan, error)
+	Graph(ctx context.Context, p GraphParams) (graph.Graph, error)
 
 	DumpBuildDefinition(ctx context.Context, configPath string) (string, error)
 	DumpCompiledBuildDefinition(ctx context.Context, configPath string) (string, error)
@@ -30,6 +35,22 @@ type BuildParams struct {
 	BuildDefinition string
 
 	ForcePull bool
+	Jobs      int
+	Platforms []string
+}
+
+type PlanParams struct {
+	Targets         []string // Target names to plan
+	BuildDefinition string
+
+	StubVars  bool
+	Jobs      int // Max count of targets executed concurrently
+	Platforms []string
+}
+
+type GraphParams struct {
+	Targets         []string // Target names which graph is returned
+	BuildDefinition string
 }
 
 func NewBuildService(
@@ -54,37 +75,144 @@ type buildService struct {
 }
 
 func (service *buildService) Build(ctx context.Context, p BuildParams) error {
-	c, err := service.configParser.Parse(p.BuildDefinition)
+	err := validatePlatforms(p.Platforms)
 	if err != nil {
 		return err
 	}
 
-	definition, err := service.definitionBuilder.Build(c, service.config.Secrets)
+	r, err := service.resolveTargets(p.BuildDefinition, p.Targets)
 	if err != nil {
 		return err
 	}
 
-	vertex, err := service.buildVertex(p.Targets, definition)
+	return service.builder.Build(
+		ctx,
+		r.vertex,
+		r.definition.Vars,
+		service.secrets(),
+		api.BuildParams{
+			ForcePull:   p.ForcePull,
+			Jobs:        p.Jobs,
+			RemoteCache: r.remoteCache,
+			Platforms:   p.Platforms,
+			SSHAgents:   service.sshAgents(),
+		},
+	)
+}
+
+func (service *buildService) Plan(ctx context.Context, p PlanParams) (api.Plan, error) {
+	err := validatePlatforms(p.Platforms)
 	if err != nil {
-		return err
+		return api.Plan{}, err
+	}
+
+	r, err := service.resolveTargets(p.BuildDefinition, p.Targets)
+	if err != nil {
+		return api.Plan{}, err
+	}
+
+	return service.builder.Plan(
+		ctx,
+		r.vertex,
+		r.definition.Vars,
+		service.secrets(),
+		api.PlanParams{
+			StubVars:    p.StubVars,
+			Jobs:        p.Jobs,
+			Platforms:   p.Platforms,
+			SSHAgents:   service.sshAgents(),
+			RemoteCache: r.remoteCache,
+		},
+	)
+}
+
+func (service *buildService) Graph(_ context.Context, p GraphParams) (graph.Graph, error) {
+	r, err := service.resolveTargets(p.BuildDefinition, p.Targets)
+	if err != nil {
+		return graph.Graph{}, err
+	}
+
+	return graph.FromVertex(r.vertex), nil
+}
+
+// validatePlatforms checks that platforms are in os/arch[/variant] format
+func validatePlatforms(platforms []string) error {
+	seen := maps.Set[string]{}
+	for _, platform := range platforms {
+		parts := strings.Split(platform, "/")
+		valid := len(parts) == 2 || len(parts) == 3
+		for _, part := range parts {
+			valid = valid && part != ""
+		}
+		if !valid {
+			return errors.Errorf("invalid platform %q, expected os/arch[/variant]", platform)
+		}
+		if seen.Has(platform) {
+			return errors.Errorf("duplicated platform %s", platform)
+		}
+		seen.Add(platform)
 	}
+	return nil
+}
+
+type resolvedTargets struct {
+	vertex      api.Vertex
+	definition  builddefinition.Definition
+	remoteCache []api.RemoteCache
+}
 
-	secrets := slices.Map(service.config.Secrets, func(s appconfig.Secret) api.SecretSrc {
+func (service *buildService) resolveTargets(buildDefinition string, targets []string) (resolvedTargets, error) {
+	c, err := service.configParser.Parse(buildDefinition)
+	if err != nil {
+		return resolvedTargets{}, err
+	}
+
+	definition, err := service.definitionBuilder.Build(c, service.config.Secrets, service.config.SSHAgents)
+	if err != nil {
+		return resolvedTargets{}, err
+	}
+
+	vertex, err := service.buildVertex(targets, definition)
+	if err != nil {
+		return resolvedTargets{}, err
+	}
+
+	// Remote cache of project overrides host one
+	remoteCache := maybe.MapNone(c.RemoteCache, func() []appconfig.RemoteCache {
+		return service.config.RemoteCache
+	})
+
+	return resolvedTargets{
+		vertex:     vertex,
+		definition: definition,
+		remoteCache: slices.Map(remoteCache, func(rc appconfig.RemoteCache) api.RemoteCache {
+			return api.RemoteCache{
+				Type:     api.RemoteCacheType(rc.Type),
+				Ref:      rc.Ref,
+				Path:     rc.Path,
+				Mode:     string(rc.Mode),
+				ReadOnly: rc.ReadOnly,
+			}
+		}),
+	}, nil
+}
+
+func (service *buildService) secrets() []api.SecretSrc {
+	return slices.Map(service.config.Secrets, func(s appconfig.Secret) api.SecretSrc {
 		return api.SecretSrc{
 			ID:         s.ID,
 			SourcePath: s.Path,
 		}
 	})
+}
 
-	return service.builder.Build(
-		ctx,
-		vertex,
-		definition.Vars,
-		secrets,
-		api.BuildParams{
-			ForcePull: p.ForcePull,
-		},
-	)
+func (service *buildService) sshAgents() []api.SSHAgentSrc {
+	return slices.Map(service.config.SSHAgents, func(a appconfig.SSHAgent) api.SSHAgentSrc {
+		return api.SSHAgentSrc{
+			ID:         a.ID,
+			SourcePath: a.Path,
+		}
+	})
 }
 
 func (service *buildService) DumpBuildDefinition(_ context.Context, configPath string) (string, error) {
@@ -93,7 +221,7 @@ func (service *buildService) DumpBuildDefinition(_ context.Context, configPath s
 		return "", err
 	}
 
-	definition, err := service.definitionBuilder.Build(c, service.config.Secrets)
+	definition, err := service.definitionBuilder.Build(c, service.config.Secrets, service.config.SSHAgents)
 	if err != nil {
 		return "", err
 	}
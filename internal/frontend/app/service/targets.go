@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
+)
+
+type ListTargetsParams struct {
+	BuildDefinition string
+	ShowHidden      bool
+}
+
+type TargetInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	From        string   `json:"from,omitempty"`
+	DependsOn   []string `json:"dependsOn,omitempty"`
+	Output      bool     `json:"output"`
+	Hidden      bool     `json:"hidden,omitempty"`
+}
+
+type TargetsService interface {
+	ListTargets(ctx context.Context, p ListTargetsParams) ([]TargetInfo, error)
+}
+
+func NewTargetsService(configParser buildconfig.Parser) TargetsService {
+	return &targetsService{configParser: configParser}
+}
+
+type targetsService struct {
+	configParser buildconfig.Parser
+}
+
+func (service *targetsService) ListTargets(_ context.Context, p ListTargetsParams) ([]TargetInfo, error) {
+	c, err := service.configParser.Parse(p.BuildDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]TargetInfo, 0, len(c.Targets))
+	for _, t := range c.Targets {
+		if t.Hidden && !p.ShowHidden {
+			continue
+		}
+
+		info := TargetInfo{
+			Name:        t.Name,
+			Description: t.Description,
+			DependsOn:   t.DependsOn,
+			Hidden:      t.Hidden,
+		}
+		if maybe.Valid(t.Stage) {
+			stage := maybe.Just(t.Stage)
+			info.From = stage.From
+			info.Output = len(stage.Outputs) > 0
+		}
+
+		targets = append(targets, info)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+
+	return targets, nil
+}
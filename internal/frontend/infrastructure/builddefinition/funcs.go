@@ -50,6 +50,40 @@ var funcs = []nativeFunc{
 			}, nil
 		},
 	},
+	nativeFunc2[string, string]{
+		name: "bind",
+		v1: argDesc{
+			name: "src",
+		},
+		v2: argDesc{
+			name: "dst",
+		},
+		f: func(src string, dst string) (interface{}, error) {
+			return map[string]interface{}{
+				"src": src,
+				"dst": dst,
+			}, nil
+		},
+	},
+	nativeFunc3[string, string, string]{
+		name: "bindFrom",
+		v1: argDesc{
+			name: "from",
+		},
+		v2: argDesc{
+			name: "src",
+		},
+		v3: argDesc{
+			name: "dst",
+		},
+		f: func(from string, src string, dst string) (interface{}, error) {
+			return map[string]interface{}{
+				"from": from,
+				"src":  src,
+				"dst":  dst,
+			}, nil
+		},
+	},
 	nativeFunc2[string, string]{
 		name: "secret",
 		v1: argDesc{
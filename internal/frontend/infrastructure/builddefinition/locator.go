@@ -0,0 +1,132 @@
+package builddefinition
+
+import (
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
+// sourceLocator finds location of field in jsonnet sources of build definition and its imports.
+// Since field may be produced by jsonnet functions, location is a best guess: field with same name
+// which enclosing fields match path most of all
+type sourceLocator struct {
+	vm      *jsonnet.VM
+	visited maps.Set[string]
+	fields  []sourceField
+}
+
+type sourceField struct {
+	name    string
+	parents []string // Names of enclosing fields
+	loc     ast.LocationRange
+}
+
+func newSourceLocator(vm *jsonnet.VM, filename, snippet string) *sourceLocator {
+	locator := &sourceLocator{
+		vm:      vm,
+		visited: maps.Set[string]{},
+	}
+
+	root, err := jsonnet.SnippetToAST(filename, snippet)
+	if err != nil {
+		// Snippet already compiled, so there should not be parse errors. Anyway location is optional
+		return locator
+	}
+
+	locator.visited.Add(filename)
+	locator.walk(root, filename, nil)
+
+	return locator
+}
+
+func (locator *sourceLocator) locate(path fieldPath, field string) maybe.Maybe[ast.LocationRange] {
+	var (
+		found     bool
+		bestLoc   ast.LocationRange
+		bestScore = -1
+	)
+
+	for _, f := range locator.fields {
+		if f.name != field {
+			continue
+		}
+
+		score := matchScore(path, f.parents)
+		if score > bestScore {
+			found, bestLoc, bestScore = true, f.loc, score
+		}
+	}
+
+	if !found {
+		return maybe.NewNone[ast.LocationRange]()
+	}
+	return maybe.NewJust(bestLoc)
+}
+
+func (locator *sourceLocator) walk(node ast.Node, filename string, parents []string) {
+	if node == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *ast.DesugaredObject:
+		for _, f := range n.Fields {
+			name, ok := f.Name.(*ast.LiteralString)
+			if !ok {
+				locator.walk(f.Name, filename, parents)
+				locator.walk(f.Body, filename, parents)
+				continue
+			}
+
+			locator.fields = append(locator.fields, sourceField{
+				name:    name.Value,
+				parents: parents,
+				loc:     f.LocRange,
+			})
+			locator.walk(f.Body, filename, append(parents[:len(parents):len(parents)], name.Value))
+		}
+		for _, a := range n.Asserts {
+			locator.walk(a, filename, parents)
+		}
+		for _, l := range n.Locals {
+			locator.walk(l.Body, filename, parents)
+		}
+		return
+	case *ast.Import:
+		locator.walkImport(filename, n.File.Value)
+		return
+	}
+
+	for _, child := range toolutils.Children(node) {
+		locator.walk(child, filename, parents)
+	}
+}
+
+func (locator *sourceLocator) walkImport(importedFrom, importedPath string) {
+	node, foundAt, err := locator.vm.ImportAST(importedFrom, importedPath)
+	if err != nil || locator.visited.Has(foundAt) {
+		return
+	}
+	locator.visited.Add(foundAt)
+
+	locator.walk(node, foundAt, nil)
+}
+
+// matchScore counts elements of path that found in parents in the same order
+func matchScore(path fieldPath, parents []string) int {
+	var score int
+	i := 0
+	for _, elem := range path {
+		for j := i; j < len(parents); j++ {
+			if parents[j] == elem {
+				score++
+				i = j + 1
+				break
+			}
+		}
+	}
+	return score
+}
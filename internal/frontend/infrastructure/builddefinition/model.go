@@ -3,17 +3,21 @@ package builddefinition
 import (
 	"github.com/ispringtech/brewkit/internal/common/either"
 	"github.com/ispringtech/brewkit/internal/common/maybe"
+	infraconfig "github.com/ispringtech/brewkit/internal/frontend/infrastructure/config"
 )
 
 type Config struct {
 	APIVersion string                                     `json:"apiVersion"`
 	Targets    map[string]either.Either[[]string, Target] `json:"targets"`
 	Vars       map[string]Var                             `json:"vars"`
+	Cache      maybe.Maybe[[]infraconfig.RemoteCache]     `json:"cache"` // Same format as cache of host config
 }
 
 type Target struct {
-	DependsOn []string `json:"dependsOn"`
-	*Stage    `json:",inline"`
+	Description string   `json:"description"`
+	Hidden      bool     `json:"hidden"`
+	DependsOn   []string `json:"dependsOn"`
+	*Stage      `json:",inline"`
 }
 
 type Stage struct {
@@ -21,13 +25,16 @@ type Stage struct {
 	Env      map[string]string               `json:"env"`
 	SSH      maybe.Maybe[SSH]                `json:"ssh"`
 	Cache    []Cache                         `json:"cache"`
+	Tmpfs    []Tmpfs                         `json:"tmpfs"`
 	Copy     either.Either[[]Copy, Copy]     `json:"copy"`
+	Mount    either.Either[[]Mount, Mount]   `json:"mount"`
 	Secrets  either.Either[[]Secret, Secret] `json:"secret"`
 	Platform maybe.Maybe[string]             `json:"platform"`
 	WorkDir  string                          `json:"workdir"`
 	Network  maybe.Maybe[string]             `json:"network"`
 	Command  maybe.Maybe[string]             `json:"command"`
-	Output   maybe.Maybe[Output]             `json:"output"`
+	Output   either.Either[[]Output, Output] `json:"output"`
+	Image    maybe.Maybe[Image]              `json:"image"`
 }
 
 type Var struct {
@@ -36,16 +43,36 @@ type Var struct {
 	WorkDir  string                          `json:"workdir"`
 	Env      map[string]string               `json:"env"`
 	Cache    []Cache                         `json:"cache"`
+	Tmpfs    []Tmpfs                         `json:"tmpfs"`
 	Copy     either.Either[[]Copy, Copy]     `json:"copy"`
 	Secrets  either.Either[[]Secret, Secret] `json:"secrets"`
 	Network  maybe.Maybe[string]             `json:"network"`
 	SSH      maybe.Maybe[SSH]                `json:"ssh"`
 	Command  string                          `json:"command"`
+	UseCache bool                            `json:"useCache"`
 }
 
 type Cache struct {
-	ID   string `yaml:"id"`
-	Path string `yaml:"path"`
+	ID       string              `json:"id"`
+	Path     string              `json:"path"`
+	Sharing  maybe.Maybe[string] `json:"sharing"`
+	UID      maybe.Maybe[int]    `json:"uid"`
+	GID      maybe.Maybe[int]    `json:"gid"`
+	Mode     maybe.Maybe[string] `json:"mode"`
+	ReadOnly bool                `json:"readonly"`
+	From     maybe.Maybe[string] `json:"from"`
+	Source   maybe.Maybe[string] `json:"source"`
+}
+
+type Tmpfs struct {
+	Path string              `json:"path"`
+	Size maybe.Maybe[string] `json:"size"`
+}
+
+type Mount struct {
+	From maybe.Maybe[string] `json:"from"`
+	Src  string              `json:"src"`
+	Dst  string              `json:"dst"`
 }
 
 type Copy struct {
@@ -54,14 +81,25 @@ type Copy struct {
 	Dst  string              `json:"dst"`
 }
 
-type SSH struct{}
+type SSH struct {
+	ID maybe.Maybe[string] `json:"id"`
+}
 
 type Secret struct {
-	ID   string `yaml:"id"`
-	Path string `yaml:"path"`
+	ID   string `json:"id"`
+	Path string `json:"path"`
 }
 
 type Output struct {
-	Artifact string `json:"artifact"`
-	Local    string `json:"local"`
+	Artifact string              `json:"artifact"`
+	Local    string              `json:"local"`
+	Rename   maybe.Maybe[string] `json:"rename"`
+	Type     maybe.Maybe[string] `json:"type"`
+}
+
+type Image struct {
+	Tags   []string          `json:"tags"`
+	Labels map[string]string `json:"labels"`
+	Push   bool              `json:"push"`
+	Load   bool              `json:"load"`
 }
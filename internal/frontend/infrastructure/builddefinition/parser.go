@@ -2,8 +2,11 @@ package builddefinition
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 
 	"github.com/google/go-jsonnet"
 	"github.com/pkg/errors"
@@ -12,12 +15,26 @@ import (
 	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/common/slices"
 	"github.com/ispringtech/brewkit/internal/frontend/app/buildconfig"
+	"github.com/ispringtech/brewkit/internal/frontend/app/config"
+	infraconfig "github.com/ispringtech/brewkit/internal/frontend/infrastructure/config"
 )
 
 type Parser struct{}
 
 func (parser Parser) Parse(configPath string) (buildconfig.Config, error) {
-	data, err := parser.compileConfig(configPath)
+	snippet, err := parser.readConfig(configPath)
+	if err != nil {
+		return buildconfig.Config{}, err
+	}
+
+	vm := parser.makeVM()
+
+	data, err := parser.compileConfig(vm, configPath, snippet)
+	if err != nil {
+		return buildconfig.Config{}, err
+	}
+
+	err = parser.validateConfig(vm, configPath, snippet, data)
 	if err != nil {
 		return buildconfig.Config{}, err
 	}
@@ -29,35 +46,93 @@ func (parser Parser) Parse(configPath string) (buildconfig.Config, error) {
 		return buildconfig.Config{}, errors.Wrap(err, "failed to parse json config")
 	}
 
-	return mapConfig(c), nil
+	return mapConfig(c)
 }
 
 func (parser Parser) CompileConfig(configPath string) (string, error) {
-	return parser.compileConfig(configPath)
+	snippet, err := parser.readConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+
+	return parser.compileConfig(parser.makeVM(), configPath, snippet)
 }
 
-func (parser Parser) compileConfig(configPath string) (string, error) {
+func (parser Parser) readConfig(configPath string) (string, error) {
 	fileBytes, err := os.ReadFile(configPath)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to read build config file")
 	}
 
+	return string(fileBytes), nil
+}
+
+func (parser Parser) makeVM() *jsonnet.VM {
 	vm := jsonnet.MakeVM()
 
 	for _, f := range funcs {
 		vm.NativeFunction(f.nativeFunc())
 	}
 
-	data, err := vm.EvaluateAnonymousSnippet(path.Base(configPath), string(fileBytes))
+	return vm
+}
+
+func (parser Parser) compileConfig(vm *jsonnet.VM, configPath, snippet string) (string, error) {
+	data, err := vm.EvaluateAnonymousSnippet(path.Base(configPath), snippet)
 	return data, errors.Wrap(err, "failed to compile jsonnet for build definition")
 }
 
-func mapConfig(c Config) buildconfig.Config {
-	return buildconfig.Config{
-		APIVersion: c.APIVersion,
-		Targets:    mapTargets(c.Targets),
-		Vars:       mapVars(c.Vars),
+// validateConfig reports unknown and mistyped fields of compiled config with their location in jsonnet sources
+func (parser Parser) validateConfig(vm *jsonnet.VM, configPath, snippet, data string) error {
+	var value interface{}
+	err := json.Unmarshal([]byte(data), &value)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse json config")
+	}
+
+	v := validator{}
+	v.validate(value, reflect.TypeOf(Config{}), nil)
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	locator := newSourceLocator(vm, path.Base(configPath), snippet)
+
+	messages := make([]string, 0, len(v.errors))
+	for _, fieldErr := range v.errors {
+		msg := fieldErr.message
+		if p := fieldErr.path.String(); p != "" {
+			msg = fmt.Sprintf("%s: %s", p, msg)
+		}
+
+		if fieldErr.field != "" {
+			loc := locator.locate(fieldErr.path, fieldErr.field)
+			if maybe.Valid(loc) {
+				l := maybe.Just(loc)
+				msg = fmt.Sprintf("%s:%d:%d: %s", l.FileName, l.Begin.Line, l.Begin.Column, msg)
+			}
+		}
+
+		messages = append(messages, msg)
 	}
+
+	return errors.Errorf("invalid build definition:\n%s", strings.Join(messages, "\n"))
+}
+
+func mapConfig(c Config) (buildconfig.Config, error) {
+	remoteCache, err := maybe.MapErr(c.Cache, func(cache []infraconfig.RemoteCache) ([]config.RemoteCache, error) {
+		return slices.MapErr(cache, infraconfig.MapRemoteCache)
+	})
+	if err != nil {
+		return buildconfig.Config{}, err
+	}
+
+	return buildconfig.Config{
+		APIVersion:  c.APIVersion,
+		Targets:     mapTargets(c.Targets),
+		Vars:        mapVars(c.Vars),
+		RemoteCache: remoteCache,
+	}, nil
 }
 
 func mapTargets(targets map[string]either.Either[[]string, Target]) []buildconfig.TargetData {
@@ -75,9 +150,11 @@ func mapTargets(targets map[string]either.Either[[]string, Target]) []buildconfi
 				s := maybe.FromPtr(t.Stage)
 
 				result = append(result, buildconfig.TargetData{
-					Name:      name,
-					DependsOn: t.DependsOn,
-					Stage:     maybe.Map(s, mapStage),
+					Name:        name,
+					Description: t.Description,
+					Hidden:      t.Hidden,
+					DependsOn:   t.DependsOn,
+					Stage:       maybe.Map(s, mapStage),
 				})
 			})
 	}
@@ -94,15 +171,20 @@ func mapStage(stage Stage) buildconfig.StageData {
 		Command:  stage.Command,
 		SSH:      mapSSH(stage.SSH),
 		Cache:    slices.Map(stage.Cache, mapCache),
+		Tmpfs:    slices.Map(stage.Tmpfs, mapTmpfs),
 		Copy:     parseCopy(stage.Copy),
+		Mounts:   parseMount(stage.Mount),
 		Secrets:  parseSecret(stage.Secrets),
 		Network: maybe.Map(stage.Network, func(n string) string {
 			return n
 		}),
-		Output: maybe.Map(stage.Output, func(o Output) buildconfig.Output {
-			return buildconfig.Output{
-				Artifact: o.Artifact,
-				Local:    o.Local,
+		Outputs: parseOutput(stage.Output),
+		Image: maybe.Map(stage.Image, func(i Image) buildconfig.Image {
+			return buildconfig.Image{
+				Tags:   i.Tags,
+				Labels: i.Labels,
+				Push:   i.Push,
+				Load:   i.Load,
 			}
 		}),
 	}
@@ -125,25 +207,43 @@ func mapVar(name string, v Var) buildconfig.VarData {
 		Env:      v.Env,
 		SSH:      mapSSH(v.SSH),
 		Cache:    slices.Map(v.Cache, mapCache),
+		Tmpfs:    slices.Map(v.Tmpfs, mapTmpfs),
 		Copy:     parseCopy(v.Copy),
 		Secrets:  parseSecret(v.Secrets),
 		Network: maybe.Map(v.Network, func(n string) string {
 			return n
 		}),
-		Command: v.Command,
+		Command:  v.Command,
+		UseCache: v.UseCache,
 	}
 }
 
 func mapSSH(ssh maybe.Maybe[SSH]) maybe.Maybe[buildconfig.SSH] {
 	return maybe.Map(ssh, func(s SSH) buildconfig.SSH {
-		return buildconfig.SSH{}
+		return buildconfig.SSH{
+			ID: s.ID,
+		}
 	})
 }
 
 func mapCache(cache Cache) buildconfig.Cache {
 	return buildconfig.Cache{
-		ID:   cache.ID,
-		Path: cache.Path,
+		ID:       cache.ID,
+		Path:     cache.Path,
+		Sharing:  cache.Sharing,
+		UID:      cache.UID,
+		GID:      cache.GID,
+		Mode:     cache.Mode,
+		ReadOnly: cache.ReadOnly,
+		From:     cache.From,
+		Source:   cache.Source,
+	}
+}
+
+func mapTmpfs(tmpfs Tmpfs) buildconfig.Tmpfs {
+	return buildconfig.Tmpfs{
+		Path: tmpfs.Path,
+		Size: tmpfs.Size,
 	}
 }
 
@@ -166,6 +266,45 @@ func mapCopy(c Copy) buildconfig.Copy {
 	}
 }
 
+func parseMount(m either.Either[[]Mount, Mount]) (result []buildconfig.Mount) {
+	m.
+		MapLeft(func(l []Mount) {
+			result = slices.Map(l, mapMount)
+		}).
+		MapRight(func(r Mount) {
+			result = append(result, mapMount(r))
+		})
+	return result
+}
+
+func mapMount(m Mount) buildconfig.Mount {
+	return buildconfig.Mount{
+		Src:  m.Src,
+		Dst:  m.Dst,
+		From: m.From,
+	}
+}
+
+func parseOutput(o either.Either[[]Output, Output]) (result []buildconfig.Output) {
+	o.
+		MapLeft(func(l []Output) {
+			result = slices.Map(l, mapOutput)
+		}).
+		MapRight(func(r Output) {
+			result = append(result, mapOutput(r))
+		})
+	return result
+}
+
+func mapOutput(o Output) buildconfig.Output {
+	return buildconfig.Output{
+		Artifact: o.Artifact,
+		Local:    o.Local,
+		Rename:   o.Rename,
+		Type:     o.Type,
+	}
+}
+
 func parseSecret(s either.Either[[]Secret, Secret]) (result []buildconfig.Secret) {
 	s.
 		MapLeft(func(l []Secret) {
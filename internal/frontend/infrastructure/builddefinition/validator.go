@@ -0,0 +1,338 @@
+package builddefinition
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maybeTyped is implemented by maybe.Maybe
+type maybeTyped interface {
+	InnerType() reflect.Type
+}
+
+// eitherTyped is implemented by either.Either
+type eitherTyped interface {
+	InnerTypes() (l, r reflect.Type)
+}
+
+var (
+	maybeTypedType  = reflect.TypeOf((*maybeTyped)(nil)).Elem()
+	eitherTypedType = reflect.TypeOf((*eitherTyped)(nil)).Elem()
+)
+
+// fieldError describes invalid field of compiled build definition
+type fieldError struct {
+	path    fieldPath
+	field   string // Name of field to search in jsonnet sources
+	message string
+}
+
+// fieldPath is path to field in compiled build definition, e.g. [targets gobuild copy [0] src]
+type fieldPath []string
+
+func (p fieldPath) append(elem string) fieldPath {
+	res := make(fieldPath, 0, len(p)+1)
+	res = append(res, p...)
+	return append(res, elem)
+}
+
+func (p fieldPath) String() string {
+	const (
+		targetsField = "targets"
+		varsField    = "vars"
+	)
+
+	var prefix string
+	rest := p
+	if len(p) >= 2 {
+		switch p[0] {
+		case targetsField:
+			prefix, rest = fmt.Sprintf("target %s", p[1]), p[2:]
+		case varsField:
+			prefix, rest = fmt.Sprintf("var %s", p[1]), p[2:]
+		}
+	}
+
+	b := strings.Builder{}
+	for _, elem := range rest {
+		if b.Len() > 0 && !strings.HasPrefix(elem, "[") {
+			b.WriteString(".")
+		}
+		b.WriteString(elem)
+	}
+
+	switch {
+	case prefix == "":
+		return b.String()
+	case b.Len() == 0:
+		return prefix
+	default:
+		return fmt.Sprintf("%s: %s", prefix, b.String())
+	}
+}
+
+// validator checks compiled build definition against model types, so unknown and misspelled fields are not ignored
+type validator struct {
+	errors []fieldError
+}
+
+func (v *validator) validate(value interface{}, t reflect.Type, path fieldPath) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if value == nil {
+		// null treated as absent value
+		return
+	}
+
+	if inner, ok := maybeType(t); ok {
+		v.validate(value, inner, path)
+		return
+	}
+
+	if l, r, ok := eitherTypes(t); ok {
+		switch {
+		case kindMatches(value, l):
+			v.validate(value, l, path)
+		case kindMatches(value, r):
+			v.validate(value, r, path)
+		default:
+			v.addError(path, fmt.Sprintf("expected %s or %s, got %s", jsonKind(l), jsonKind(r), jsonValueKind(value)))
+		}
+		return
+	}
+
+	if !kindMatches(value, t) {
+		v.addError(path, fmt.Sprintf("expected %s, got %s", jsonKind(t), jsonValueKind(value)))
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj := value.(map[string]interface{})
+		fields := structFields(t)
+		for _, key := range sortedKeys(obj) {
+			fieldType, known := fields[key]
+			if !known {
+				v.errors = append(v.errors, fieldError{
+					path:    path,
+					field:   key,
+					message: unknownFieldMessage(key, fields),
+				})
+				continue
+			}
+			v.validate(obj[key], fieldType, path.append(key))
+		}
+	case reflect.Map:
+		obj := value.(map[string]interface{})
+		for _, key := range sortedKeys(obj) {
+			v.validate(obj[key], t.Elem(), path.append(key))
+		}
+	case reflect.Slice:
+		for i, elem := range value.([]interface{}) {
+			v.validate(elem, t.Elem(), path.append("["+strconv.Itoa(i)+"]"))
+		}
+	default:
+	}
+}
+
+func (v *validator) addError(path fieldPath, message string) {
+	var field string
+	if len(path) > 0 && !strings.HasPrefix(path[len(path)-1], "[") {
+		field = path[len(path)-1]
+	}
+
+	v.errors = append(v.errors, fieldError{
+		path:    path,
+		field:   field,
+		message: message,
+	})
+}
+
+func unknownFieldMessage(key string, fields map[string]reflect.Type) string {
+	msg := fmt.Sprintf("unknown field %q", key)
+
+	const maxDistance = 2
+	suggestion, bestDistance := "", maxDistance+1
+	for name := range fields {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if distance < bestDistance || (distance == bestDistance && name < suggestion) {
+			suggestion, bestDistance = name, distance
+		}
+	}
+
+	if suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return msg
+}
+
+// structFields returns json names of struct fields with their types, inlined embedded structs are flattened
+func structFields(t reflect.Type) map[string]reflect.Type {
+	res := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			for k, v := range structFields(embedded) {
+				res[k] = v
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		res[name] = f.Type
+	}
+	return res
+}
+
+func maybeType(t reflect.Type) (reflect.Type, bool) {
+	if !t.Implements(maybeTypedType) {
+		return nil, false
+	}
+	return reflect.Zero(t).Interface().(maybeTyped).InnerType(), true
+}
+
+func eitherTypes(t reflect.Type) (l, r reflect.Type, ok bool) {
+	if !t.Implements(eitherTypedType) {
+		return nil, nil, false
+	}
+	l, r = reflect.Zero(t).Interface().(eitherTyped).InnerTypes()
+	return l, r, true
+}
+
+// kindMatches reports whether decoded json value may be unmarshalled to t
+func kindMatches(value interface{}, t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if inner, ok := maybeType(t); ok {
+		return kindMatches(value, inner)
+	}
+
+	if l, r, ok := eitherTypes(t); ok {
+		return kindMatches(value, l) || kindMatches(value, r)
+	}
+
+	switch value.(type) {
+	case map[string]interface{}:
+		return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	case []interface{}:
+		return t.Kind() == reflect.Slice
+	case string:
+		return t.Kind() == reflect.String
+	case bool:
+		return t.Kind() == reflect.Bool
+	case float64:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if inner, ok := maybeType(t); ok {
+		return jsonKind(inner)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+func jsonValueKind(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,70 @@
+package builddefinition
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatorChecksMaybeAndEitherFields(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{
+			name:   "valid maybe and either",
+			config: `{"apiVersion": "brewkit/v1", "targets": {"all": ["app"], "app": {"from": "alpine", "command": "true", "copy": {"from": "a", "src": "b", "dst": "c"}}}}`,
+		},
+		{
+			name:   "null maybe",
+			config: `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "command": null}}}`,
+		},
+		{
+			name:     "mistyped maybe",
+			config:   `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "command": 1}}}`,
+			expected: []string{"target app: command: expected string, got number"},
+		},
+		{
+			name:     "unknown field inside maybe",
+			config:   `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "image": {"tag": ["app"]}}}}`,
+			expected: []string{`target app: image: unknown field "tag", did you mean "tags"?`},
+		},
+		{
+			name:   "either left",
+			config: `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "copy": [{"src": "a", "dst": "b"}]}}}`,
+		},
+		{
+			name:     "mistyped either",
+			config:   `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "copy": "a"}}}`,
+			expected: []string{"target app: copy: expected array or object, got string"},
+		},
+		{
+			name:     "unknown field inside either",
+			config:   `{"apiVersion": "brewkit/v1", "targets": {"app": {"from": "alpine", "copy": [{"src": "a", "dts": "b"}]}}}`,
+			expected: []string{`target app: copy[0]: unknown field "dts", did you mean "dst"?`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var value interface{}
+			err := json.Unmarshal([]byte(tc.config), &value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := validator{}
+			v.validate(value, reflect.TypeOf(Config{}), nil)
+
+			messages := make([]string, 0, len(v.errors))
+			for _, fieldErr := range v.errors {
+				messages = append(messages, fieldErr.path.String()+": "+fieldErr.message)
+			}
+			if strings.Join(messages, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("expected errors %q, got %q", tc.expected, messages)
+			}
+		})
+	}
+}
@@ -1,10 +1,41 @@
 package config
 
+import (
+	"github.com/ispringtech/brewkit/internal/common/maybe"
+)
+
 type Config struct {
-	Secrets []Secret `json:"secrets"`
+	Secrets []Secret             `json:"secrets"`
+	SSH     []SSHAgent           `json:"ssh"`
+	Backend maybe.Maybe[Backend] `json:"backend"`
+	Cache   []RemoteCache        `json:"cache"`
+	Tracing maybe.Maybe[Tracing] `json:"tracing"`
 }
 
 type Secret struct {
 	ID   string `json:"id"`
 	Path string `json:"path"`
 }
+
+type SSHAgent struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type Backend struct {
+	Type    string              `json:"type"`
+	Address maybe.Maybe[string] `json:"address"`
+}
+
+type RemoteCache struct {
+	Type     string `json:"type"`
+	Ref      string `json:"ref,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	ReadOnly bool   `json:"readonly,omitempty"`
+}
+
+type Tracing struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
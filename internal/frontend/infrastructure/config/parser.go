@@ -8,6 +8,8 @@ import (
 	"github.com/google/go-jsonnet"
 	"github.com/pkg/errors"
 
+	"github.com/ispringtech/brewkit/internal/common/maps"
+	"github.com/ispringtech/brewkit/internal/common/maybe"
 	"github.com/ispringtech/brewkit/internal/common/slices"
 	"github.com/ispringtech/brewkit/internal/frontend/app/config"
 )
@@ -40,6 +42,21 @@ func (p Parser) Config(configPath string) (config.Config, error) {
 		return config.Config{}, errors.Wrap(err, "failed to parse json config")
 	}
 
+	backend, err := mapBackend(c.Backend)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	remoteCache, err := slices.MapErr(c.Cache, MapRemoteCache)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	sshAgents, err := mapSSHAgents(c.SSH)
+	if err != nil {
+		return config.Config{}, err
+	}
+
 	return config.Config{
 		Secrets: slices.Map(c.Secrets, func(s Secret) config.Secret {
 			return config.Secret{
@@ -47,6 +64,15 @@ func (p Parser) Config(configPath string) (config.Config, error) {
 				Path: os.ExpandEnv(s.Path),
 			}
 		}),
+		SSHAgents:   sshAgents,
+		Backend:     backend,
+		RemoteCache: remoteCache,
+		Tracing: maybe.Map(c.Tracing, func(t Tracing) config.Tracing {
+			return config.Tracing{
+				Endpoint: os.ExpandEnv(t.Endpoint),
+				Insecure: t.Insecure,
+			}
+		}),
 	}, nil
 }
 
@@ -58,8 +84,95 @@ func (p Parser) Dump(srcConfig config.Config) ([]byte, error) {
 				Path: s.Path,
 			}
 		}),
+		SSH: slices.Map(srcConfig.SSHAgents, func(a config.SSHAgent) SSHAgent {
+			return SSHAgent{
+				ID:   a.ID,
+				Path: a.Path,
+			}
+		}),
+		Backend: maybe.NewJust(Backend{
+			Type:    string(srcConfig.Backend.Type),
+			Address: srcConfig.Backend.Address,
+		}),
+		Cache: slices.Map(srcConfig.RemoteCache, func(c config.RemoteCache) RemoteCache {
+			return RemoteCache{
+				Type:     string(c.Type),
+				Ref:      c.Ref,
+				Path:     c.Path,
+				Mode:     string(c.Mode),
+				ReadOnly: c.ReadOnly,
+			}
+		}),
+		Tracing: maybe.Map(srcConfig.Tracing, func(t config.Tracing) Tracing {
+			return Tracing{
+				Endpoint: t.Endpoint,
+				Insecure: t.Insecure,
+			}
+		}),
 	}
 
 	data, err := json.Marshal(c)
 	return data, errors.Wrap(err, "failed to marshal config to json")
 }
+
+func mapBackend(backend maybe.Maybe[Backend]) (config.Backend, error) {
+	if !maybe.Valid(backend) {
+		return config.DefaultConfig.Backend, nil
+	}
+
+	b := maybe.Just(backend)
+
+	backendType := config.BackendType(b.Type)
+	switch backendType {
+	case config.DockerBackend, config.BuildKitBackend:
+	default:
+		return config.Backend{}, errors.Errorf("unknown backend type %s", b.Type)
+	}
+
+	return config.Backend{
+		Type: backendType,
+		Address: maybe.Map(b.Address, func(address string) string {
+			return os.ExpandEnv(address)
+		}),
+	}, nil
+}
+
+func mapSSHAgents(agents []SSHAgent) ([]config.SSHAgent, error) {
+	ids := maps.Set[string]{}
+	return slices.MapErr(agents, func(a SSHAgent) (config.SSHAgent, error) {
+		if a.ID == "" {
+			return config.SSHAgent{}, errors.New("ssh agent without id")
+		}
+		if a.Path == "" {
+			return config.SSHAgent{}, errors.Errorf("path is required for %s ssh agent", a.ID)
+		}
+		if ids.Has(a.ID) {
+			return config.SSHAgent{}, errors.Errorf("duplicated ssh agent %s", a.ID)
+		}
+		ids.Add(a.ID)
+
+		return config.SSHAgent{
+			ID:   a.ID,
+			Path: os.ExpandEnv(a.Path),
+		}, nil
+	})
+}
+
+// MapRemoteCache maps and validates remote cache of host config and of build definition, which uses the same format
+func MapRemoteCache(c RemoteCache) (config.RemoteCache, error) {
+	mode := config.MinCacheMode
+	if c.Mode != "" {
+		mode = config.RemoteCacheMode(c.Mode)
+	}
+
+	res := config.RemoteCache{
+		Type:     config.RemoteCacheType(c.Type),
+		Ref:      os.ExpandEnv(c.Ref),
+		Path:     os.ExpandEnv(c.Path),
+		Mode:     mode,
+		ReadOnly: c.ReadOnly,
+	}
+
+	err := config.ValidateRemoteCache(res)
+	return res, errors.Wrap(err, "invalid cache")
+}